@@ -3,14 +3,49 @@ package clients
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gagliardetto/solana-go"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"solana/models"
+	"strconv"
+	"strings"
+	"time"
 )
 
-const heliusApi = "https://api.helius.xyz/v0"
+const (
+	DefaultHeliusBaseURL = "https://api.helius.xyz/v0"
+	defaultHeliusTimeout = 30 * time.Second
+	// maxHeliusPageSize is the most transactions Helius returns per page
+	maxHeliusPageSize     = 100
+	defaultHeliusMaxPages = 10
+)
+
+// searchWindowContinuation finds the signature to continue from in the error Helius answers when a type or
+// source filtered query finds nothing in its search window, e.g. "... query the API again with the `before`
+// parameter set to <sig>" or "before=<sig>"
+var searchWindowContinuation = regexp.MustCompile("before(?:-signature)?`?(?:=| parameter set to )([1-9A-HJ-NP-Za-km-z]+)")
+
+// searchWindowError is the answer of Helius to a filtered query that found nothing in its search window. The
+// history goes on before Before.
+type searchWindowError struct {
+	Before string
+}
+
+func (swe *searchWindowError) Error() string {
+	return "no transactions in the search window, continue before " + swe.Before
+}
+
+// HeliusAPI is the part of the Helius API the services use
+type HeliusAPI interface {
+	GetWebhookConfig() (*WebhookConfig, error)
+	UpdateWebhookConfig(configRequest *WebhookConfigRequest) (*WebhookConfig, error)
+	GetAccountTokenTransactions(address string, mintSignature string) ([]HeliusTransactionResponse, error)
+	AddressTransactions(address string, query AddressTransactionsQuery) *AddressTransactionsIterator
+}
 
 type WebhookConfig struct {
 	WebhookID        string   `json:"webhookID"`
@@ -31,8 +66,49 @@ type WebhookConfigRequest struct {
 }
 
 type HeliusClient struct {
-	apiKey    string
-	webhookID string
+	apiKey     string
+	webhookID  string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// HeliusOption configures a HeliusClient
+type HeliusOption func(*heliusOptions)
+
+type heliusOptions struct {
+	baseURL    string
+	timeout    time.Duration
+	transport  http.RoundTripper
+	httpClient *http.Client
+}
+
+// WithHeliusBaseURL points the client at another deployment of the API, such as devnet or a local fake
+func WithHeliusBaseURL(baseURL string) HeliusOption {
+	return func(o *heliusOptions) {
+		o.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHeliusTimeout limits the time of a whole request, including reading the response
+func WithHeliusTimeout(timeout time.Duration) HeliusOption {
+	return func(o *heliusOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithHeliusTransport sends the requests through the given transport
+func WithHeliusTransport(transport http.RoundTripper) HeliusOption {
+	return func(o *heliusOptions) {
+		o.transport = transport
+	}
+}
+
+// WithHeliusHTTPClient sends the requests with the given client. A timeout or transport given as well
+// override the ones of the client, which itself is left untouched.
+func WithHeliusHTTPClient(httpClient *http.Client) HeliusOption {
+	return func(o *heliusOptions) {
+		o.httpClient = httpClient
+	}
 }
 
 type HeliusTransactionResponse struct {
@@ -45,22 +121,38 @@ type HeliusTransactionResponse struct {
 	Slot             uint64                      `json:"slot"`
 	Timestamp        uint64                      `json:"timestamp"`
 	TokenTransfers   []models.TokenIO            `json:"tokenTransfers"`
-	NativeTransfers  []models.TokenIO            `json:"nativeTransfers"`
+	NativeTransfers  []models.NativeTransfer     `json:"nativeTransfers"`
 	AccountData      []models.AccountData        `json:"accountData"`
 	TransactionError interface{}                 `json:"transactionError"`
 	Instructions     []solana.GenericInstruction `json:"instructions"`
 	Events           interface{}                 `json:"events"`
 }
 
-func NewHeliusClient(apiKey, webhookID string) *HeliusClient {
-	return &HeliusClient{apiKey: apiKey, webhookID: webhookID}
+// NewHeliusClient returns a client of the Helius API at DefaultHeliusBaseURL with a timeout of 30 seconds,
+// unless configured otherwise by the options
+func NewHeliusClient(apiKey, webhookID string, opts ...HeliusOption) *HeliusClient {
+	options := heliusOptions{baseURL: DefaultHeliusBaseURL}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	httpClient := &http.Client{Timeout: defaultHeliusTimeout}
+	if options.httpClient != nil {
+		copied := *options.httpClient
+		httpClient = &copied
+	}
+	if options.timeout > 0 {
+		httpClient.Timeout = options.timeout
+	}
+	if options.transport != nil {
+		httpClient.Transport = options.transport
+	}
+	return &HeliusClient{apiKey: apiKey, webhookID: webhookID, baseURL: options.baseURL, httpClient: httpClient}
 }
 
 func (hc *HeliusClient) GetWebhookConfig() (*WebhookConfig, error) {
 	logger.Info("Getting webhook config", "webhookID", hc.webhookID)
-	url := fmt.Sprintf("%s/webhooks/%s?api-key=%s", heliusApi, hc.webhookID, hc.apiKey)
-
-	client := &http.Client{}
+	url := fmt.Sprintf("%s/webhooks/%s?api-key=%s", hc.baseURL, hc.webhookID, hc.apiKey)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -68,7 +160,7 @@ func (hc *HeliusClient) GetWebhookConfig() (*WebhookConfig, error) {
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := hc.httpClient.Do(req)
 	if err != nil {
 		logger.Error("Error getting webhook config", "error", err)
 		return nil, err
@@ -104,7 +196,7 @@ func (hc *HeliusClient) GetWebhookConfig() (*WebhookConfig, error) {
 
 func (hc *HeliusClient) UpdateWebhookConfig(configRequest *WebhookConfigRequest) (*WebhookConfig, error) {
 	logger.Info("Updating webhook config", "webhookID", hc.webhookID)
-	url := fmt.Sprintf("%s/webhooks/%s?api-key=%s", heliusApi, hc.webhookID, hc.apiKey)
+	url := fmt.Sprintf("%s/webhooks/%s?api-key=%s", hc.baseURL, hc.webhookID, hc.apiKey)
 
 	requestBody, err := json.Marshal(configRequest)
 	if err != nil {
@@ -112,8 +204,6 @@ func (hc *HeliusClient) UpdateWebhookConfig(configRequest *WebhookConfigRequest)
 		return nil, fmt.Errorf("error marshalling webhook config request")
 	}
 
-	client := &http.Client{}
-
 	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		logger.Error("Error creating webhook config request", "error", err)
@@ -121,7 +211,7 @@ func (hc *HeliusClient) UpdateWebhookConfig(configRequest *WebhookConfigRequest)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := hc.httpClient.Do(req)
 	if err != nil {
 		logger.Error("Error updating webhook config", "error", err)
 		return nil, fmt.Errorf("error updating webhook config")
@@ -154,19 +244,53 @@ func (hc *HeliusClient) UpdateWebhookConfig(configRequest *WebhookConfigRequest)
 	return &updatedConfig, nil
 }
 
+// GetAccountTokenTransactions returns the Raydium transactions of the address made after the mint signature,
+// newest first. It reads at most defaultHeliusMaxPages pages, use AddressTransactions to read more.
 func (hc *HeliusClient) GetAccountTokenTransactions(address string, mintSignature string) ([]HeliusTransactionResponse, error) {
-	url := heliusApi + "/addresses/" + address + "/transactions?source=RAYDIUM&until" + mintSignature + "&api-key=" + hc.apiKey
-	logger.Info("Getting account token transactions", "url", url)
-	req, err := http.NewRequest("GET", url, nil)
-	var transactions []HeliusTransactionResponse
+	transactions := make([]HeliusTransactionResponse, 0)
+	iterator := hc.AddressTransactions(address, AddressTransactionsQuery{Until: mintSignature, Source: "RAYDIUM"})
+	for iterator.Next() {
+		transactions = append(transactions, iterator.Transaction())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// AddressTransactions returns an iterator over the parsed transactions of the address, newest first. It reads
+// the pages one at a time while it is advanced, each starting before the last transaction of the previous one.
+func (hc *HeliusClient) AddressTransactions(address string, query AddressTransactionsQuery) *AddressTransactionsIterator {
+	return newAddressTransactionsIterator(query, func(before string) ([]HeliusTransactionResponse, error) {
+		return hc.getAddressTransactionsPage(address, query, before)
+	})
+}
+
+func (hc *HeliusClient) getAddressTransactionsPage(address string, query AddressTransactionsQuery, before string) ([]HeliusTransactionResponse, error) {
+	params := url.Values{}
+	params.Set("api-key", hc.apiKey)
+	params.Set("limit", strconv.Itoa(query.pageSize()))
+	if before != "" {
+		params.Set("before", before)
+	}
+	if query.Until != "" {
+		params.Set("until", query.Until)
+	}
+	if query.Type != "" {
+		params.Set("type", query.Type)
+	}
+	if query.Source != "" {
+		params.Set("source", query.Source)
+	}
+	logger.Info("Getting address transactions", "address", address, "before", before, "until", query.Until)
+	req, err := http.NewRequest("GET", hc.baseURL+"/addresses/"+address+"/transactions?"+params.Encode(), nil)
 	if err != nil {
 		logger.Error("Error creating request", "error", err)
 		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := hc.httpClient.Do(req)
 	if err != nil {
-		logger.Error("Error getting account token transactions", "error", err)
+		logger.Error("Error getting address transactions", "error", err)
 		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
@@ -176,16 +300,26 @@ func (hc *HeliusClient) GetAccountTokenTransactions(address string, mintSignatur
 		}
 	}(resp.Body)
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("Received non-200 status code", "status", resp.StatusCode)
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Error("Error reading response body", "error", err)
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		var answer struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &answer) == nil {
+			if match := searchWindowContinuation.FindStringSubmatch(answer.Error); match != nil {
+				logger.Info("No address transactions in the search window", "address", address, "before", match[1])
+				return nil, &searchWindowError{Before: match[1]}
+			}
+		}
+		logger.Error("Received non-200 status code", "status", resp.StatusCode, "body", string(body))
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	var transactions []HeliusTransactionResponse
 	err = json.Unmarshal(body, &transactions)
 	if err != nil {
 		logger.Error("Error unmarshalling response body", "error", err)
@@ -193,3 +327,100 @@ func (hc *HeliusClient) GetAccountTokenTransactions(address string, mintSignatur
 	}
 	return transactions, nil
 }
+
+// AddressTransactionsQuery selects the transactions of an address. Before and Until are signatures bounding the
+// history, Type and Source filter it by the Helius transaction type and source. PageSize defaults to
+// maxHeliusPageSize and MaxPages to defaultHeliusMaxPages.
+type AddressTransactionsQuery struct {
+	Before   string
+	Until    string
+	Type     string
+	Source   string
+	PageSize int
+	MaxPages int
+}
+
+func (q AddressTransactionsQuery) pageSize() int {
+	if q.PageSize <= 0 || q.PageSize > maxHeliusPageSize {
+		return maxHeliusPageSize
+	}
+	return q.PageSize
+}
+
+// AddressTransactionsIterator streams the transactions of an address page by page:
+//
+//	for iterator.Next() {
+//		transaction := iterator.Transaction()
+//	}
+//	if err := iterator.Err(); err != nil {
+//		...
+//	}
+//
+// It stops at the end of the history, at the first error or once it read the maximum number of pages. A filtered
+// query that finds nothing in a search window goes on from the signature Helius names, which counts as a page.
+type AddressTransactionsIterator struct {
+	fetch    func(before string) ([]HeliusTransactionResponse, error)
+	before   string
+	maxPages int
+	pages    int
+	page     []HeliusTransactionResponse
+	index    int
+	current  HeliusTransactionResponse
+	done     bool
+	err      error
+}
+
+func newAddressTransactionsIterator(query AddressTransactionsQuery, fetch func(before string) ([]HeliusTransactionResponse, error)) *AddressTransactionsIterator {
+	maxPages := query.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultHeliusMaxPages
+	}
+	return &AddressTransactionsIterator{fetch: fetch, before: query.Before, maxPages: maxPages}
+}
+
+// Next advances to the next transaction, reading the next page when the current one is used up, and reports
+// whether there is one
+func (it *AddressTransactionsIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.pages >= it.maxPages {
+			logger.Info("Stopped reading address transactions at the page limit", "pages", it.pages, "before", it.before)
+			it.done = true
+			return false
+		}
+		page, err := it.fetch(it.before)
+		it.pages++
+		var window *searchWindowError
+		if errors.As(err, &window) {
+			it.before = window.Before
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		// Only an empty page ends the history or reaches the until signature. Filtered by type or source, Helius
+		// answers short pages before that.
+		if len(page) == 0 {
+			it.done = true
+		} else {
+			it.before = page[len(page)-1].Signature
+		}
+		it.page, it.index = page, 0
+	}
+	it.current = it.page[it.index]
+	it.index++
+	return true
+}
+
+// Transaction returns the transaction Next advanced to
+func (it *AddressTransactionsIterator) Transaction() HeliusTransactionResponse {
+	return it.current
+}
+
+// Err returns the error that stopped the iterator, if any
+func (it *AddressTransactionsIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,210 @@
+package clients
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the requests it passes on
+type countingTransport struct {
+	requests int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHeliusClientOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/webhooks/webhook" || r.URL.Query().Get("api-key") != "key" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"webhookID": "webhook", "accountAddresses": ["A1"]}`))
+	}))
+	defer server.Close()
+
+	var _ HeliusAPI = &HeliusClient{}
+
+	t.Run("sends requests to the base URL through the transport", func(t *testing.T) {
+		transport := &countingTransport{}
+		hc := NewHeliusClient("key", "webhook", WithHeliusBaseURL(server.URL+"/"), WithHeliusTransport(transport))
+		config, err := hc.GetWebhookConfig()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.WebhookID != "webhook" || len(config.AccountAddresses) != 1 {
+			t.Errorf("Incorrect webhook config %+v", config)
+		}
+		if transport.requests != 1 {
+			t.Errorf("Incorrect number of requests %d should be %d", transport.requests, 1)
+		}
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer slow.Close()
+		hc := NewHeliusClient("key", "webhook", WithHeliusBaseURL(slow.URL), WithHeliusTimeout(50*time.Millisecond))
+		start := time.Now()
+		if _, err := hc.GetWebhookConfig(); err == nil {
+			t.Error("Expected an error")
+		}
+		if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+			t.Errorf("Expected the request to time out, it took %v", elapsed)
+		}
+	})
+
+	t.Run("leaves an injected client untouched", func(t *testing.T) {
+		injected := &http.Client{Timeout: time.Minute}
+		hc := NewHeliusClient("key", "webhook", WithHeliusHTTPClient(injected), WithHeliusTimeout(time.Second))
+		if injected.Timeout != time.Minute || hc.httpClient.Timeout != time.Second {
+			t.Errorf("Incorrect timeouts %v and %v", injected.Timeout, hc.httpClient.Timeout)
+		}
+	})
+
+	t.Run("defaults to Helius with a timeout", func(t *testing.T) {
+		hc := NewHeliusClient("key", "webhook")
+		if hc.baseURL != DefaultHeliusBaseURL || hc.httpClient.Timeout != defaultHeliusTimeout {
+			t.Errorf("Incorrect defaults %s and %v", hc.baseURL, hc.httpClient.Timeout)
+		}
+	})
+}
+
+func TestAddressTransactions(t *testing.T) {
+	// The history of the address runs from T5 (newest) to T1
+	history := []string{"T5", "T4", "T3", "T2", "T1"}
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Path != "/addresses/A1/transactions" || query.Get("api-key") != "key" || query.Get("source") != "RAYDIUM" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if query.Get("before") == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// Filtered by type, nothing newer than T3 matches in the first search window
+		if query.Get("type") != "" && query.Get("before") == "" {
+			w.WriteHeader(http.StatusNotFound)
+			message := "Failed to find events within the search period. To continue search, query the API again with the `before` parameter set to T3."
+			if query.Get("type") == "TRANSFER" {
+				message = "no transactions found, continue with before=T3"
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+			return
+		}
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		page := make([]HeliusTransactionResponse, 0)
+		started := query.Get("before") == ""
+		for _, signature := range history {
+			if signature == query.Get("until") || len(page) == limit {
+				break
+			}
+			if started {
+				page = append(page, HeliusTransactionResponse{Signature: signature})
+			}
+			started = started || signature == query.Get("before")
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+	hc := NewHeliusClient("key", "webhook", WithHeliusBaseURL(server.URL))
+
+	collect := func(iterator *AddressTransactionsIterator) []string {
+		signatures := make([]string, 0)
+		for iterator.Next() {
+			signatures = append(signatures, iterator.Transaction().Signature)
+		}
+		return signatures
+	}
+
+	t.Run("follows the before cursor until the until signature", func(t *testing.T) {
+		queries = nil
+		iterator := hc.AddressTransactions("A1", AddressTransactionsQuery{Until: "T1", Source: "RAYDIUM", PageSize: 3})
+		signatures := collect(iterator)
+		if iterator.Err() != nil {
+			t.Fatalf("Unexpected error: %v", iterator.Err())
+		}
+		if len(signatures) != 4 || signatures[0] != "T5" || signatures[3] != "T2" {
+			t.Errorf("Incorrect signatures %v should be [T5 T4 T3 T2]", signatures)
+		}
+		// The end of the history is an empty page
+		if len(queries) != 3 {
+			t.Errorf("Incorrect number of pages %d should be %d", len(queries), 3)
+		}
+	})
+
+	t.Run("stops at the page limit", func(t *testing.T) {
+		iterator := hc.AddressTransactions("A1", AddressTransactionsQuery{Before: "T5", Source: "RAYDIUM", PageSize: 1, MaxPages: 2})
+		if signatures := collect(iterator); len(signatures) != 2 || signatures[1] != "T3" {
+			t.Errorf("Incorrect signatures %v should be [T4 T3]", signatures)
+		}
+	})
+
+	t.Run("reads on after a short page", func(t *testing.T) {
+		pages := map[string][]HeliusTransactionResponse{
+			"":   {{Signature: "T5"}},
+			"T5": {{Signature: "T4"}, {Signature: "T3"}},
+		}
+		iterator := newAddressTransactionsIterator(AddressTransactionsQuery{PageSize: 3}, func(before string) ([]HeliusTransactionResponse, error) {
+			return pages[before], nil
+		})
+		if signatures := collect(iterator); len(signatures) != 3 || signatures[2] != "T3" {
+			t.Errorf("Incorrect signatures %v should be [T5 T4 T3]", signatures)
+		}
+	})
+
+	t.Run("continues from the signature of an empty search window", func(t *testing.T) {
+		for _, transactionType := range []string{"SWAP", "TRANSFER"} {
+			queries = nil
+			iterator := hc.AddressTransactions("A1", AddressTransactionsQuery{Type: transactionType, Source: "RAYDIUM", PageSize: 3})
+			signatures := collect(iterator)
+			if iterator.Err() != nil {
+				t.Fatalf("Unexpected error: %v", iterator.Err())
+			}
+			if len(signatures) != 2 || signatures[0] != "T2" || signatures[1] != "T1" {
+				t.Errorf("Incorrect signatures %v for %s should be [T2 T1]", signatures, transactionType)
+			}
+			if len(queries) != 3 {
+				t.Errorf("Incorrect number of pages %d for %s should be %d", len(queries), transactionType, 3)
+			}
+		}
+	})
+
+	t.Run("counts an empty search window against the page limit", func(t *testing.T) {
+		queries = nil
+		iterator := hc.AddressTransactions("A1", AddressTransactionsQuery{Type: "SWAP", Source: "RAYDIUM", MaxPages: 1})
+		if signatures := collect(iterator); len(signatures) != 0 || iterator.Err() != nil {
+			t.Errorf("Incorrect result %v, %v should be no transactions and no error", signatures, iterator.Err())
+		}
+		if len(queries) != 1 {
+			t.Errorf("Incorrect number of pages %d should be %d", len(queries), 1)
+		}
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		iterator := hc.AddressTransactions("A1", AddressTransactionsQuery{Before: "fail", Source: "RAYDIUM"})
+		if iterator.Next() || iterator.Err() == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("collects the token transactions", func(t *testing.T) {
+		transactions, err := hc.GetAccountTokenTransactions("A1", "T3")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(transactions) != 2 {
+			t.Errorf("Incorrect number of transactions %d should be %d", len(transactions), 2)
+		}
+	})
+}
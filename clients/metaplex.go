@@ -0,0 +1,198 @@
+package clients
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"solana/models"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+const (
+	// metadataNameOffset skips the key, update authority and mint that precede the name in a Metaplex metadata account
+	metadataNameOffset = 1 + 32 + 32
+
+	metadataDocumentTimeout = 5 * time.Second
+	// maxMetadataDocumentSize caps the off-chain metadata documents read for their logo
+	maxMetadataDocumentSize = 64 << 10
+	maxMetadataRedirects    = 3
+)
+
+var (
+	errInvalidMetadata = errors.New("invalid metaplex metadata account")
+	// errUnsafeMetadataURI refuses off-chain metadata anyone could point at internal services
+	errUnsafeMetadataURI = errors.New("off-chain metadata must be served over https from a public address")
+)
+
+// MetaplexClient reads token metadata from the chain: the decimals from the mint and the name, symbol and URI
+// from its Metaplex metadata account. The logo is the image of the JSON document the URI points to, which is
+// only read by FetchLogo.
+type MetaplexClient struct {
+	rpc    *rpc.Client
+	client *http.Client
+}
+
+func NewMetaplexClient(rpcClient *rpc.Client) *MetaplexClient {
+	return &MetaplexClient{rpc: rpcClient, client: newMetadataDocumentClient()}
+}
+
+// newMetadataDocumentClient returns a client that only connects to public addresses over https. The addresses
+// are checked once resolved, so host names pointing at internal addresses are refused as well.
+func newMetadataDocumentClient() *http.Client {
+	dialer := &net.Dialer{Timeout: metadataDocumentTimeout, Control: publicAddressOnly}
+	return &http.Client{
+		Timeout:   metadataDocumentTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: metadataDocumentTimeout},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" {
+				return errUnsafeMetadataURI
+			}
+			if len(via) >= maxMetadataRedirects {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return errUnsafeMetadataURI
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not public either
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !sharedAddressSpace.Contains(ip)
+}
+
+// FetchTokenMetadata returns the metadata of the mint. Mints without a Metaplex metadata account only get their
+// decimals.
+func (mc *MetaplexClient) FetchTokenMetadata(mint string) (*models.TokenMetadata, error) {
+	mintKey, err := solana.PublicKeyFromBase58(mint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint %s: %w", mint, err)
+	}
+
+	supply, err := mc.rpc.GetTokenSupply(context.Background(), mintKey, rpc.CommitmentFinalized)
+	if err != nil {
+		logger.Error("Error getting token supply", "error", err, "mint", mint)
+		return nil, err
+	}
+	token := &models.TokenMetadata{
+		Mint:      mint,
+		Decimals:  int(supply.Value.Decimals),
+		Source:    models.TokenSourceMetaplex,
+		UpdatedAt: time.Now(),
+	}
+
+	metadataAddress, _, err := solana.FindTokenMetadataAddress(mintKey)
+	if err != nil {
+		return nil, err
+	}
+	account, err := mc.rpc.GetAccountInfo(context.Background(), metadataAddress)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return token, nil
+	}
+	if err != nil {
+		logger.Error("Error getting metadata account", "error", err, "mint", mint)
+		return nil, err
+	}
+
+	name, symbol, uri, err := decodeMetadata(account.Value.Data.GetBinary())
+	if err != nil {
+		logger.Error("Error decoding metadata account", "error", err, "mint", mint)
+		return nil, err
+	}
+	token.Name = name
+	token.Symbol = symbol
+	token.URI = uri
+	return token, nil
+}
+
+// FetchLogo returns the image of the off-chain metadata document. Only https documents on public addresses are
+// read, up to maxMetadataDocumentSize.
+func (mc *MetaplexClient) FetchLogo(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "https" {
+		return "", errUnsafeMetadataURI
+	}
+	resp, err := mc.client.Get(parsed.String())
+	if err != nil {
+		logger.Debug("Error getting off-chain metadata", "error", err, "uri", uri)
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
+		if err != nil {
+			logger.Error("Error closing response body", "error", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Debug("Received non-200 status code", "status", resp.StatusCode, "uri", uri)
+		return "", fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+	return decodeLogo(resp.Body)
+}
+
+// decodeLogo reads the image of an off-chain metadata document, failing on documents over the size cap
+func decodeLogo(body io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxMetadataDocumentSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxMetadataDocumentSize {
+		return "", errors.New("off-chain metadata document is too large")
+	}
+	var document struct {
+		Image string `json:"image"`
+	}
+	if err = json.Unmarshal(data, &document); err != nil {
+		return "", err
+	}
+	return document.Image, nil
+}
+
+// decodeMetadata reads the name, symbol and URI of a Metaplex metadata account. They are borsh strings padded
+// with null bytes.
+func decodeMetadata(data []byte) (name string, symbol string, uri string, err error) {
+	if len(data) < metadataNameOffset {
+		return "", "", "", errInvalidMetadata
+	}
+	offset := metadataNameOffset
+	fields := make([]string, 3)
+	for i := range fields {
+		if len(data) < offset+4 {
+			return "", "", "", errInvalidMetadata
+		}
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		offset += 4
+		if len(data) < offset+length {
+			return "", "", "", errInvalidMetadata
+		}
+		fields[i] = strings.TrimSpace(strings.TrimRight(string(data[offset:offset+length]), "\x00"))
+		offset += length
+	}
+	return fields[0], fields[1], fields[2], nil
+}
@@ -0,0 +1,101 @@
+package clients
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func borshString(value string, padding int) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(value)+padding))
+	data = append(data, value...)
+	return append(data, make([]byte, padding)...)
+}
+
+func TestDecodeMetadata(t *testing.T) {
+	t.Run("Decodes padded name, symbol and uri", func(t *testing.T) {
+		data := make([]byte, metadataNameOffset)
+		data = append(data, borshString("Bonk", 28)...)
+		data = append(data, borshString("BONK", 6)...)
+		data = append(data, borshString("https://arweave.net/bonk", 176)...)
+		data = append(data, 0xff)
+
+		name, symbol, uri, err := decodeMetadata(data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if name != "Bonk" || symbol != "BONK" || uri != "https://arweave.net/bonk" {
+			t.Errorf("Incorrect metadata: %q %q %q", name, symbol, uri)
+		}
+	})
+	t.Run("Rejects truncated accounts", func(t *testing.T) {
+		data := make([]byte, metadataNameOffset)
+		data = append(data, borshString("Bonk", 28)[:10]...)
+		if _, _, _, err := decodeMetadata(data); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestFetchLogo(t *testing.T) {
+	mc := &MetaplexClient{client: newMetadataDocumentClient()}
+
+	t.Run("Refuses documents not served over https", func(t *testing.T) {
+		for _, uri := range []string{"http://arweave.net/bonk", "file:///etc/passwd", "arweave.net/bonk"} {
+			if _, err := mc.FetchLogo(uri); !errors.Is(err, errUnsafeMetadataURI) {
+				t.Errorf("Incorrect error %v for %s should be %v", err, uri, errUnsafeMetadataURI)
+			}
+		}
+	})
+	t.Run("Refuses documents on internal addresses", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("The document should not be requested")
+		}))
+		defer server.Close()
+
+		if _, err := mc.FetchLogo(server.URL); !errors.Is(err, errUnsafeMetadataURI) {
+			t.Errorf("Incorrect error %v should be %v", err, errUnsafeMetadataURI)
+		}
+	})
+	t.Run("Reads the image of the document", func(t *testing.T) {
+		logo, err := decodeLogo(strings.NewReader(`{"name":"Bonk","image":"https://arweave.net/bonk.png"}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if logo != "https://arweave.net/bonk.png" {
+			t.Errorf("Incorrect logo %q should be %q", logo, "https://arweave.net/bonk.png")
+		}
+	})
+	t.Run("Refuses documents over the size cap", func(t *testing.T) {
+		document := `{"image":"https://arweave.net/bonk.png","padding":"` + strings.Repeat("a", maxMetadataDocumentSize) + `"}`
+		if _, err := decodeLogo(strings.NewReader(document)); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"104.18.10.20":     true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := isPublicIP(net.ParseIP(address)); got != public {
+			t.Errorf("Incorrect result %v for %s should be %v", got, address, public)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// priceTimeout bounds a price request, prices are looked up while webhook transactions are processed
+const priceTimeout = 5 * time.Second
+
+// PriceClient reads USD token prices from an API following the Jupiter price API v2 format:
+// GET <baseURL>?ids=<mint>,<mint> answering {"data": {"<mint>": {"price": "<decimal>"}}}
+type PriceClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+type priceResponse struct {
+	Data map[string]*struct {
+		ID    string `json:"id"`
+		Price string `json:"price"`
+	} `json:"data"`
+}
+
+func NewPriceClient(baseURL string) *PriceClient {
+	return &PriceClient{baseURL: baseURL, client: &http.Client{Timeout: priceTimeout}}
+}
+
+// GetPrices returns the USD prices of the mints as decimal strings. Mints without a price are left out.
+func (pc *PriceClient) GetPrices(mints []string) (map[string]string, error) {
+	requestURL := pc.baseURL + "?ids=" + url.QueryEscape(strings.Join(mints, ","))
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		logger.Error("Error creating price request", "error", err)
+		return nil, err
+	}
+	resp, err := pc.client.Do(req)
+	if err != nil {
+		logger.Error("Error getting prices", "error", err)
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err = Body.Close()
+		if err != nil {
+			logger.Error("Error closing response body", "error", err)
+		}
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("Received non-200 status code", "status", resp.StatusCode)
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("Error reading response body", "error", err)
+		return nil, err
+	}
+	var response priceResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		logger.Error("Error unmarshalling prices", "error", err)
+		return nil, err
+	}
+
+	prices := make(map[string]string, len(response.Data))
+	for mint, price := range response.Data {
+		if price != nil && price.Price != "" {
+			prices[mint] = price.Price
+		}
+	}
+	return prices, nil
+}
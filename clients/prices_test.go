@@ -0,0 +1,42 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPriceClient(t *testing.T) {
+	t.Run("returns the known prices", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("ids") != "SOL,BONK" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data": {"SOL": {"id": "SOL", "price": "142.3371"}, "BONK": null}}`))
+		}))
+		defer server.Close()
+
+		prices, err := NewPriceClient(server.URL).GetPrices([]string{"SOL", "BONK"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(prices) != 1 || prices["SOL"] != "142.3371" {
+			t.Errorf("Incorrect prices %v", prices)
+		}
+	})
+
+	t.Run("gives up on a hanging price API", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer slow.Close()
+
+		pc := NewPriceClient(slow.URL)
+		pc.client.Timeout = 50 * time.Millisecond
+		if _, err := pc.GetPrices([]string{"SOL"}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
@@ -0,0 +1,257 @@
+package clients
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	redisDialTimeout    = 5 * time.Second
+	redisCommandTimeout = 5 * time.Second
+	// redisPingInterval is how long a subscription waits for a message before it checks the connection
+	redisPingInterval = 30 * time.Second
+)
+
+// RedisClient speaks just enough of the Redis protocol (RESP) to publish and subscribe, so it works with Redis
+// and any server compatible with it. Commands share one connection, which is dialed again after a failure.
+// Every command has to be answered within the timeout.
+type RedisClient struct {
+	addr         string
+	password     string
+	timeout      time.Duration
+	pingInterval time.Duration
+	mu           sync.Mutex
+	conn         net.Conn
+	reader       *bufio.Reader
+}
+
+// RedisError is an error reply of the server
+type RedisError string
+
+func (re RedisError) Error() string {
+	return string(re)
+}
+
+// NewRedisClient returns a client for a redis://[:password@]host:port URL
+func NewRedisClient(redisURL string) (*RedisClient, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "redis" || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid redis URL %q", redisURL)
+	}
+	password, _ := parsed.User.Password()
+	return &RedisClient{addr: parsed.Host, password: password, timeout: redisCommandTimeout, pingInterval: redisPingInterval}, nil
+}
+
+func (rc *RedisClient) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", rc.addr, redisDialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	if rc.password != "" {
+		if _, err = roundTrip(conn, reader, rc.timeout, "AUTH", rc.password); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, reader, nil
+}
+
+// Do sends the command and returns its reply: a string, an int64, nil or a []interface{} of those
+func (rc *RedisClient) Do(args ...string) (interface{}, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.conn == nil {
+		conn, reader, err := rc.dial()
+		if err != nil {
+			logger.Error("Error connecting to redis", "error", err, "addr", rc.addr)
+			return nil, err
+		}
+		rc.conn, rc.reader = conn, reader
+	}
+	reply, err := roundTrip(rc.conn, rc.reader, rc.timeout, args...)
+	var redisError RedisError
+	if err != nil && !errors.As(err, &redisError) {
+		// The connection is in an unknown state, the next command dials a new one
+		_ = rc.conn.Close()
+		rc.conn, rc.reader = nil, nil
+	}
+	return reply, err
+}
+
+// Publish sends the message to the subscribers of the channel
+func (rc *RedisClient) Publish(channel string, message []byte) error {
+	_, err := rc.Do("PUBLISH", channel, string(message))
+	return err
+}
+
+// Subscribe opens a connection of its own subscribed to the channel
+func (rc *RedisClient) Subscribe(channel string) (*RedisSubscription, error) {
+	conn, reader, err := rc.dial()
+	if err != nil {
+		logger.Error("Error connecting to redis", "error", err, "addr", rc.addr)
+		return nil, err
+	}
+	if _, err = roundTrip(conn, reader, rc.timeout, "SUBSCRIBE", channel); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &RedisSubscription{conn: conn, reader: reader, timeout: rc.timeout, pingInterval: rc.pingInterval}, nil
+}
+
+func (rc *RedisClient) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.conn == nil {
+		return nil
+	}
+	err := rc.conn.Close()
+	rc.conn, rc.reader = nil, nil
+	return err
+}
+
+// RedisSubscription receives the messages published to a channel. A subscription without messages for the ping
+// interval pings the server, which has to answer within the timeout, so a dead connection does not go unnoticed.
+type RedisSubscription struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	timeout      time.Duration
+	pingInterval time.Duration
+}
+
+// Receive blocks until the next message arrives. After an error the subscription is done.
+func (rs *RedisSubscription) Receive() ([]byte, error) {
+	pinged := false
+	for {
+		wait := rs.pingInterval
+		if pinged {
+			wait = rs.timeout
+		}
+		if err := rs.conn.SetReadDeadline(time.Now().Add(wait)); err != nil {
+			return nil, err
+		}
+		// Waiting for the start of a reply consumes nothing, so a quiet connection can be pinged and read on
+		if _, err := rs.reader.Peek(1); err != nil {
+			var netErr net.Error
+			if pinged || !errors.As(err, &netErr) || !netErr.Timeout() {
+				return nil, err
+			}
+			if err = rs.ping(); err != nil {
+				return nil, err
+			}
+			pinged = true
+			continue
+		}
+		pinged = false
+
+		if err := rs.conn.SetReadDeadline(time.Now().Add(rs.timeout)); err != nil {
+			return nil, err
+		}
+		reply, err := readReply(rs.reader)
+		if err != nil {
+			return nil, err
+		}
+		// Pushed messages are ["message", channel, payload], anything else is a reply to the subscription
+		push, ok := reply.([]interface{})
+		if !ok || len(push) != 3 || push[0] != "message" {
+			continue
+		}
+		payload, _ := push[2].(string)
+		return []byte(payload), nil
+	}
+}
+
+// ping asks the server for a pong, which a subscribed connection receives as ["pong", ""]
+func (rs *RedisSubscription) ping() error {
+	if err := rs.conn.SetWriteDeadline(time.Now().Add(rs.timeout)); err != nil {
+		return err
+	}
+	_, err := rs.conn.Write(encodeCommand([]string{"PING"}))
+	return err
+}
+
+func (rs *RedisSubscription) Close() error {
+	return rs.conn.Close()
+}
+
+// roundTrip sends the command and reads its reply, both within the timeout
+func roundTrip(conn net.Conn, reader *bufio.Reader, timeout time.Duration, args ...string) (interface{}, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(reader)
+}
+
+// encodeCommand encodes the command as an array of bulk strings
+func encodeCommand(args []string) []byte {
+	command := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		command = append(command, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		command = append(command, arg...)
+		command = append(command, "\r\n"...)
+	}
+	return command
+}
+
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, RedisError(value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			items[i], err = readReply(reader)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+}
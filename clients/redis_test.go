@@ -0,0 +1,193 @@
+package clients
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redisStandIn is a local server answering the commands RedisClient sends like Redis does. It leaves DEBUG SLEEP
+// unanswered, and PING too once it is unresponsive.
+type redisStandIn struct {
+	listener     net.Listener
+	mu           sync.Mutex
+	subscribers  map[string][]net.Conn
+	unresponsive bool
+}
+
+func newRedisStandIn(t *testing.T) *redisStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	server := &redisStandIn{listener: listener, subscribers: make(map[string][]net.Conn)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (rs *redisStandIn) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	subscribed := false
+	for {
+		request, err := readReply(reader)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		args := request.([]interface{})
+		switch args[0] {
+		case "AUTH":
+			if args[1] == "secret" {
+				_, _ = conn.Write([]byte("+OK\r\n"))
+			} else {
+				_, _ = conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+			}
+		case "SUBSCRIBE":
+			channel := args[1].(string)
+			rs.mu.Lock()
+			rs.subscribers[channel] = append(rs.subscribers[channel], conn)
+			rs.mu.Unlock()
+			subscribed = true
+			_, _ = conn.Write([]byte("*3\r\n$9\r\nsubscribe\r\n$" + strconv.Itoa(len(channel)) + "\r\n" + channel + "\r\n:1\r\n"))
+		case "PUBLISH":
+			channel, message := args[1].(string), args[2].(string)
+			rs.mu.Lock()
+			subscribers := rs.subscribers[channel]
+			for _, subscriber := range subscribers {
+				_, _ = subscriber.Write(encodeCommand([]string{"message", channel, message}))
+			}
+			rs.mu.Unlock()
+			_, _ = conn.Write([]byte(":" + strconv.Itoa(len(subscribers)) + "\r\n"))
+		case "PING":
+			rs.mu.Lock()
+			unresponsive := rs.unresponsive
+			rs.mu.Unlock()
+			if unresponsive {
+				continue
+			}
+			if subscribed {
+				_, _ = conn.Write([]byte("*2\r\n$4\r\npong\r\n$0\r\n\r\n"))
+			} else {
+				_, _ = conn.Write([]byte("+PONG\r\n"))
+			}
+		case "DEBUG":
+		default:
+			_, _ = conn.Write([]byte("-ERR unknown command\r\n"))
+		}
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	server := newRedisStandIn(t)
+	redisURL := "redis://:secret@" + server.listener.Addr().String()
+
+	t.Run("delivers published messages to subscribers", func(t *testing.T) {
+		client, err := NewRedisClient(redisURL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer client.Close()
+		subscription, err := client.Subscribe("events")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer subscription.Close()
+
+		if err = client.Publish("events", []byte(`{"id": 1}`)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		message, err := subscription.Receive()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(message) != `{"id": 1}` {
+			t.Errorf("Incorrect message %s", message)
+		}
+	})
+
+	t.Run("returns error replies without dropping the connection", func(t *testing.T) {
+		client, _ := NewRedisClient(redisURL)
+		defer client.Close()
+		_, err := client.Do("FLUSHALL")
+		if _, ok := err.(RedisError); !ok {
+			t.Errorf("Expected a redis error, got %v", err)
+		}
+		if client.conn == nil {
+			t.Errorf("Expected the connection to be kept")
+		}
+	})
+
+	t.Run("pings a quiet subscription", func(t *testing.T) {
+		client, _ := NewRedisClient(redisURL)
+		defer client.Close()
+		client.pingInterval, client.timeout = 20*time.Millisecond, time.Second
+		subscription, err := client.Subscribe("quiet")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer subscription.Close()
+
+		time.AfterFunc(100*time.Millisecond, func() { _ = client.Publish("quiet", []byte("late")) })
+		message, err := subscription.Receive()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(message) != "late" {
+			t.Errorf("Incorrect message %s should be %s", message, "late")
+		}
+	})
+
+	t.Run("fails a subscription whose server stops answering", func(t *testing.T) {
+		silent := newRedisStandIn(t)
+		silent.mu.Lock()
+		silent.unresponsive = true
+		silent.mu.Unlock()
+		client, _ := NewRedisClient("redis://" + silent.listener.Addr().String())
+		client.pingInterval, client.timeout = 20*time.Millisecond, 20*time.Millisecond
+		subscription, err := client.Subscribe("events")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer subscription.Close()
+		if _, err = subscription.Receive(); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("times out a command that is not answered", func(t *testing.T) {
+		client, _ := NewRedisClient(redisURL)
+		defer client.Close()
+		client.timeout = 20 * time.Millisecond
+		if _, err := client.Do("DEBUG", "SLEEP", "1"); err == nil {
+			t.Error("Expected an error")
+		}
+		if client.conn != nil {
+			t.Errorf("Expected the connection to be dropped")
+		}
+	})
+
+	t.Run("fails to connect with a wrong password", func(t *testing.T) {
+		client, _ := NewRedisClient("redis://:wrong@" + server.listener.Addr().String())
+		if err := client.Publish("events", []byte("message")); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+
+	t.Run("rejects other URLs", func(t *testing.T) {
+		if _, err := NewRedisClient("http://localhost:6379"); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
@@ -0,0 +1,348 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// upstreamMetrics counts per upstream the requests, retries, 429 answers (throttles), waits for the rate limiter
+// and requests refused by the circuit breaker
+var upstreamMetrics = expvar.NewMap("upstreams")
+
+// TransportConfig configures a ResilientTransport. A rate of zero leaves requests unlimited, a breaker
+// threshold of zero never opens the breaker.
+type TransportConfig struct {
+	MaxRetries       int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	RatePerSecond    float64
+	Burst            int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// DefaultTransportConfig retries three times with delays between 200ms and 5s and opens the breaker after
+// five failures in a row for 30s
+var DefaultTransportConfig = TransportConfig{
+	MaxRetries:       3,
+	BaseDelay:        200 * time.Millisecond,
+	MaxDelay:         5 * time.Second,
+	Burst:            1,
+	BreakerThreshold: 5,
+	BreakerCooldown:  30 * time.Second,
+}
+
+// TransportConfigFromEnv reads the config of an upstream from <prefix>_MAX_RETRIES, <prefix>_RATE_LIMIT
+// (requests per second), <prefix>_RATE_BURST, <prefix>_BREAKER_THRESHOLD and <prefix>_BREAKER_COOLDOWN
+// (a duration such as "30s"). Unset or invalid variables keep the defaults.
+func TransportConfigFromEnv(prefix string) TransportConfig {
+	config := DefaultTransportConfig
+	if maxRetries, err := strconv.Atoi(os.Getenv(prefix + "_MAX_RETRIES")); err == nil && maxRetries >= 0 {
+		config.MaxRetries = maxRetries
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv(prefix+"_RATE_LIMIT"), 64); err == nil && rate > 0 {
+		config.RatePerSecond = rate
+	}
+	if burst, err := strconv.Atoi(os.Getenv(prefix + "_RATE_BURST")); err == nil && burst > 0 {
+		config.Burst = burst
+	}
+	if threshold, err := strconv.Atoi(os.Getenv(prefix + "_BREAKER_THRESHOLD")); err == nil && threshold >= 0 {
+		config.BreakerThreshold = threshold
+	}
+	if cooldown, err := time.ParseDuration(os.Getenv(prefix + "_BREAKER_COOLDOWN")); err == nil && cooldown > 0 {
+		config.BreakerCooldown = cooldown
+	}
+	return config
+}
+
+// ResilientTransport is an http.RoundTripper for an upstream API. It waits for the rate limiter before every
+// request and retries network errors, 429 and 5xx answers with exponential backoff and full jitter, waiting at
+// least as long as a Retry-After header asks. An answer asking to wait longer than the maximum delay is
+// returned as is. After repeated failures the circuit breaker fails requests fast until the cooldown is over.
+type ResilientTransport struct {
+	name    string
+	next    http.RoundTripper
+	config  TransportConfig
+	limiter *tokenBucket
+	breaker *circuitBreaker
+	metrics *expvar.Map
+	sleep   func(ctx context.Context, delay time.Duration) error
+	random  func() float64
+}
+
+// NewResilientTransport returns a transport for the upstream of the given name, which labels its metrics.
+// A nil next transport uses http.DefaultTransport.
+func NewResilientTransport(name string, config TransportConfig, next http.RoundTripper) *ResilientTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	metrics := new(expvar.Map).Init()
+	breaker := &circuitBreaker{threshold: config.BreakerThreshold, cooldown: config.BreakerCooldown, now: time.Now}
+	metrics.Set("breakerState", expvar.Func(func() interface{} {
+		return breaker.currentState()
+	}))
+	upstreamMetrics.Set(name, metrics)
+
+	rt := &ResilientTransport{
+		name:    name,
+		next:    next,
+		config:  config,
+		breaker: breaker,
+		metrics: metrics,
+		sleep:   sleepContext,
+		random:  rand.Float64,
+	}
+	if config.RatePerSecond > 0 {
+		rt.limiter = newTokenBucket(config.RatePerSecond, config.Burst)
+	}
+	return rt
+}
+
+func (rt *ResilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	// A body can only be sent again if the request knows how to recreate it
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if !rt.breaker.allow() {
+			rt.metrics.Add("circuitOpen", 1)
+			return nil, ErrCircuitOpen
+		}
+		if rt.limiter != nil {
+			waited, err := rt.limiter.wait(ctx, rt.sleep)
+			if err != nil {
+				// The request never reached the upstream, a trial the breaker allowed goes to the next one
+				rt.breaker.release()
+				return nil, err
+			}
+			if waited {
+				rt.metrics.Add("rateLimited", 1)
+			}
+		}
+
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				rt.breaker.release()
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		rt.metrics.Add("requests", 1)
+		resp, err := rt.next.RoundTrip(attemptReq)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the health of the upstream
+			rt.breaker.release()
+			return nil, err
+		}
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		rt.breaker.record(!failed)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			rt.metrics.Add("throttled", 1)
+		}
+		if !failed && resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		if attempt >= rt.config.MaxRetries || !retryable {
+			return resp, err
+		}
+
+		delay := rt.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				if retryAfter > rt.config.MaxDelay {
+					return resp, nil
+				}
+				if retryAfter > delay {
+					delay = retryAfter
+				}
+			}
+			// Reading the body to the end lets the connection be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		logger.Info("Retrying upstream request", "upstream", rt.name, "attempt", attempt+1, "delay", delay.String(), "error", err)
+		rt.metrics.Add("retries", 1)
+		if err = rt.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a random delay up to the base delay doubled for every attempt, capped at the maximum delay
+func (rt *ResilientTransport) backoff(attempt int) time.Duration {
+	ceiling := float64(rt.config.BaseDelay) * math.Pow(2, float64(attempt))
+	if ceiling > float64(rt.config.MaxDelay) {
+		ceiling = float64(rt.config.MaxDelay)
+	}
+	return time.Duration(rt.random() * ceiling)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// tokenBucket allows bursts of up to capacity requests and refills at rate tokens per second
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, capacity: float64(burst), tokens: float64(burst), last: time.Now(), now: time.Now}
+}
+
+// wait takes a token, sleeping until one is available, and reports whether it had to sleep
+func (tb *tokenBucket) wait(ctx context.Context, sleep func(context.Context, time.Duration) error) (bool, error) {
+	waited := false
+	for {
+		tb.mu.Lock()
+		now := tb.now()
+		tb.tokens = math.Min(tb.capacity, tb.tokens+now.Sub(tb.last).Seconds()*tb.rate)
+		tb.last = now
+		if tb.tokens >= 1 {
+			tb.tokens--
+			tb.mu.Unlock()
+			return waited, nil
+		}
+		delay := time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+		tb.mu.Unlock()
+
+		waited = true
+		if err := sleep(ctx, delay); err != nil {
+			return waited, err
+		}
+	}
+}
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker opens after threshold failures in a row. Once the cooldown is over it lets a single trial
+// request through, which closes it again on success and reopens it on failure.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = breakerHalfOpen
+		cb.trial = true
+		return true
+	case breakerHalfOpen:
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if success {
+		cb.failures = 0
+		cb.state = breakerClosed
+		cb.trial = false
+		return
+	}
+	cb.failures++
+	if cb.state == breakerHalfOpen || (cb.threshold > 0 && cb.failures >= cb.threshold) {
+		if cb.state != breakerOpen {
+			logger.Error("Opening circuit breaker", "failures", cb.failures)
+		}
+		cb.state = breakerOpen
+		cb.openedAt = cb.now()
+		cb.trial = false
+	}
+}
+
+// release lets another trial request through when the current one ended without a verdict
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trial = false
+}
+
+func (cb *circuitBreaker) currentState() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == "" {
+		return breakerClosed
+	}
+	return cb.state
+}
+
+// NewRPCClient returns a Solana RPC client sending its requests through the given transport
+func NewRPCClient(rpcURL string, transport http.RoundTripper) *rpc.Client {
+	httpClient := &http.Client{Transport: transport, Timeout: time.Minute}
+	return rpc.NewWithCustomRPCClient(jsonrpc.NewClientWithOpts(rpcURL, &jsonrpc.RPCClientOpts{HTTPClient: httpClient}))
+}
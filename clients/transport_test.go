@@ -0,0 +1,198 @@
+package clients
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTransport returns a transport that records its delays instead of sleeping
+func newTestTransport(config TransportConfig, delays *[]time.Duration) *ResilientTransport {
+	rt := NewResilientTransport("test", config, nil)
+	rt.random = func() float64 { return 1 }
+	rt.sleep = func(ctx context.Context, delay time.Duration) error {
+		*delays = append(*delays, delay)
+		return nil
+	}
+	return rt
+}
+
+func TestResilientTransport(t *testing.T) {
+	t.Run("retries 5xx answers with exponential backoff", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "payload" {
+				t.Errorf("Incorrect body %q should be %q", body, "payload")
+			}
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		var delays []time.Duration
+		client := &http.Client{Transport: newTestTransport(DefaultTransportConfig, &delays)}
+		resp, err := client.Post(server.URL, "text/plain", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Incorrect status %d should be %d", resp.StatusCode, http.StatusOK)
+		}
+		if len(delays) != 2 || delays[0] != 200*time.Millisecond || delays[1] != 400*time.Millisecond {
+			t.Errorf("Incorrect delays %v should be [200ms 400ms]", delays)
+		}
+	})
+
+	t.Run("waits as long as Retry-After asks", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.Header().Set("Retry-After", "2")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		var delays []time.Duration
+		client := &http.Client{Transport: newTestTransport(DefaultTransportConfig, &delays)}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+		if len(delays) != 1 || delays[0] != 2*time.Second {
+			t.Errorf("Incorrect delays %v should be [2s]", delays)
+		}
+	})
+
+	t.Run("returns the answer when Retry-After exceeds the maximum delay", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		var delays []time.Duration
+		client := &http.Client{Transport: newTestTransport(DefaultTransportConfig, &delays)}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests || len(delays) != 0 {
+			t.Errorf("Incorrect status %d after delays %v", resp.StatusCode, delays)
+		}
+	})
+
+	t.Run("opens the breaker after repeated failures", func(t *testing.T) {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		var delays []time.Duration
+		config := TransportConfig{MaxRetries: 0, BreakerThreshold: 2, BreakerCooldown: time.Minute}
+		rt := newTestTransport(config, &delays)
+		now := time.Now()
+		rt.breaker.now = func() time.Time { return now }
+		client := &http.Client{Transport: rt}
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			_ = resp.Body.Close()
+		}
+		if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), ErrCircuitOpen.Error()) {
+			t.Errorf("Expected the breaker to be open, got %v", err)
+		}
+		if attempts != 2 {
+			t.Errorf("Incorrect number of attempts %d should be %d", attempts, 2)
+		}
+
+		now = now.Add(time.Minute)
+		if !rt.breaker.allow() || rt.breaker.allow() {
+			t.Error("Expected a single trial request after the cooldown")
+		}
+		rt.breaker.record(true)
+		if state := rt.breaker.currentState(); state != breakerClosed {
+			t.Errorf("Incorrect breaker state %s should be %s", state, breakerClosed)
+		}
+	})
+
+	t.Run("lets another trial through when one is cancelled waiting for the rate limit", func(t *testing.T) {
+		var delays []time.Duration
+		config := TransportConfig{RatePerSecond: 1, Burst: 1, BreakerThreshold: 1, BreakerCooldown: time.Minute}
+		rt := newTestTransport(config, &delays)
+		rt.sleep = func(ctx context.Context, delay time.Duration) error {
+			return context.DeadlineExceeded
+		}
+		now := time.Now()
+		rt.breaker.now = func() time.Time { return now }
+		rt.breaker.record(false)
+		now = now.Add(time.Minute)
+		rt.limiter.tokens = 0
+
+		req, _ := http.NewRequest("GET", "http://upstream.invalid", nil)
+		if _, err := rt.RoundTrip(req); err != context.DeadlineExceeded {
+			t.Fatalf("Expected the rate limit wait to fail, got %v", err)
+		}
+		if !rt.breaker.allow() {
+			t.Error("Expected the breaker to allow another trial request")
+		}
+	})
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	tb := newTokenBucket(2, 2)
+	tb.now = func() time.Time { return now }
+	tb.last = now
+	var delays []time.Duration
+	sleep := func(ctx context.Context, delay time.Duration) error {
+		delays = append(delays, delay)
+		now = now.Add(delay)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		waited, err := tb.wait(context.Background(), sleep)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if waited != (i == 2) {
+			t.Errorf("Incorrect wait for request %d", i)
+		}
+	}
+	if len(delays) != 1 || delays[0] != 500*time.Millisecond {
+		t.Errorf("Incorrect delays %v should be [500ms]", delays)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if delay, ok := parseRetryAfter("3", now); !ok || delay != 3*time.Second {
+		t.Errorf("Incorrect delay %v should be %v", delay, 3*time.Second)
+	}
+	if delay, ok := parseRetryAfter(now.Add(10*time.Second).Format(http.TimeFormat), now); !ok || delay != 10*time.Second {
+		t.Errorf("Incorrect delay %v should be %v", delay, 10*time.Second)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("Expected an invalid header to be ignored")
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"expvar"
 	"github.com/gin-contrib/cors"
 	"log/slog"
 	"net/http"
 	"os"
 	"solana/clients"
 	"solana/db"
+	"solana/models"
 	"solana/routers"
+	"solana/services"
+	"solana/utils"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -45,11 +50,22 @@ func setupRouter() *gin.Engine {
 func initDB(dbURI string) {
 	err := db.Init(dbURI)
 	if err != nil {
-		logger.Error("Error initializing database", err)
+		logger.Error("Error initializing database", "error", err)
 		panic(err)
 	}
 }
 
+func newRedisBroker(redisURL string, channel string) (*services.PubSubBroker, error) {
+	client, err := clients.NewRedisClient(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		channel = "solana:events"
+	}
+	return services.NewRedisBroker(client, channel, 256)
+}
+
 func setupRoutes(router *gin.Engine) {
 	basicAuthAccounts := gin.Accounts{
 		os.Getenv("BASIC_AUTH_USERNAME"): os.Getenv("BASIC_AUTH_PASSWORD"),
@@ -57,21 +73,69 @@ func setupRoutes(router *gin.Engine) {
 	salt := []byte(os.Getenv("SALT"))
 	heliusAPIKey := os.Getenv("HELIUS_API_KEY")
 	heliusWebhookID := os.Getenv("HELIUS_WEBHOOK_ID")
-	rpcURL := os.Getenv("RPC_URL")
+	// Every upstream gets its own rate limiter and circuit breaker
+	rpcClient := clients.NewRPCClient(os.Getenv("RPC_URL"), clients.NewResilientTransport("rpc", clients.TransportConfigFromEnv("RPC"), nil))
+	webhookAuthHeader := os.Getenv("HELIUS_WEBHOOK_AUTH_HEADER")
+	previousWebhookAuthHeader := os.Getenv("HELIUS_WEBHOOK_AUTH_HEADER_PREVIOUS")
 
 	v1 := router.Group("/api")
 	auth := router.Group("/auth")
 	socket := router.Group("/socket")
+	stream := router.Group("/stream")
+	webhook := router.Group("/api/webhook")
 	transactionsCache := router.Group("/transactionCache")
 
+	walletNames := services.NewWalletNameCache()
+	tokens := services.NewTokenMetadataService(db.GetDB().Database("solana").Collection("tokens"), clients.NewMetaplexClient(rpcClient))
+	if err := tokens.EnsureIndexes(); err != nil {
+		logger.Error("Error ensuring token indexes", "error", err)
+	}
+	if tokenListPath := os.Getenv("TOKEN_LIST_PATH"); tokenListPath != "" {
+		if err := tokens.SeedFromFile(tokenListPath); err != nil {
+			logger.Error("Error seeding token metadata", "error", err, "path", tokenListPath)
+		}
+	}
+	routers.InitTransactionParsers(models.Resolvers{Names: walletNames, Tokens: tokens})
+	var priceSource models.PriceSource
+	if priceAPIURL := os.Getenv("PRICE_API_URL"); priceAPIURL != "" {
+		priceSource = services.NewCachedPriceSource(clients.NewPriceClient(priceAPIURL), time.Minute)
+		routers.InitPriceSource(priceSource)
+	}
+	transactionsCollection := db.GetDB().Database("solana").Collection("transactions")
+	transfersCollection := db.GetDB().Database("solana").Collection("transfers")
+	transactionCacheSize, _ := strconv.Atoi(os.Getenv("TRANSACTION_CACHE_SIZE"))
+	transactionCacheTTL, _ := time.ParseDuration(os.Getenv("TRANSACTION_CACHE_TTL"))
+	routers.InitTransactionCacheLimits(transactionCacheSize, transactionCacheTTL)
+	// Instances sharing a Redis compatible server share their events and draw their IDs from one sequence
+	var sequence services.IDSequence
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		broker, err := newRedisBroker(redisURL, os.Getenv("REDIS_EVENTS_CHANNEL"))
+		if err != nil {
+			logger.Error("Error connecting to the event broker", "error", err)
+			panic(err)
+		}
+		routers.InitEventBroker(broker)
+		sequence = services.NewMongoSequence(db.GetDB().Database("solana").Collection("counters"), "events")
+	}
+	routers.InitTransactionCache(transactionsCollection, transfersCollection, sequence)
+	routers.InitPayloadArchive(db.GetDB().Database("solana").Collection("webhookPayloads"))
+	deadLettersCollection := db.GetDB().Database("solana").Collection("deadLetters")
+	routers.InitDeadLetters(deadLettersCollection)
+	transactionsCache.Use(routers.AuthMiddleware())
 	routers.SetupCachingRoutes(transactionsCache)
 
 	v1.Use(gin.BasicAuth(basicAuthAccounts))
+	webhook.Use(routers.WebhookAuthMiddleware(webhookAuthHeader, previousWebhookAuthHeader))
 
+	socket.Use(routers.SocketAuthMiddleware())
 	socket.GET("/transactionSocket", routers.TransactionSocketHandler)
+	stream.Use(routers.SocketAuthMiddleware())
+	stream.GET("/transactions", routers.StreamTransactionsHandler)
 	router.POST("/login", routers.Login)
 	v1.POST("/register", routers.Register)
-	v1.POST("/webhook", routers.WebhookHandler)
+	webhook.POST("", routers.WebhookHandler)
+	v1.POST("/webhook/replay", routers.ReplayHandler)
+	v1.GET("/metrics", gin.WrapH(expvar.Handler()))
 	auth.Use(routers.AuthMiddleware())
 	{
 		auth.GET("", func(c *gin.Context) {
@@ -80,19 +144,40 @@ func setupRoutes(router *gin.Engine) {
 		})
 	}
 	routers.NewWalletsRouter(db.GetDB().Database("solana").Collection("wallets"), v1, salt)
-	hc := clients.NewHeliusClient(heliusAPIKey, heliusWebhookID)
-	routers.NewMonitoredWalletsRouter(db.GetDB().Database("solana").Collection("monitoredWallets"), v1, heliusAPIKey, heliusWebhookID)
-	sr := routers.NewScannerRouter(rpcURL, hc)
+	routers.NewTransactionsRouter(transactionsCollection, v1)
+	routers.NewDeadLettersRouter(deadLettersCollection, v1)
+	routers.NewTokensRouter(tokens, v1)
+	heliusOptions := []clients.HeliusOption{
+		clients.WithHeliusTransport(clients.NewResilientTransport("helius", clients.TransportConfigFromEnv("HELIUS"), nil)),
+	}
+	if heliusAPIURL := os.Getenv("HELIUS_API_URL"); heliusAPIURL != "" {
+		heliusOptions = append(heliusOptions, clients.WithHeliusBaseURL(heliusAPIURL))
+	}
+	if heliusTimeout, err := time.ParseDuration(os.Getenv("HELIUS_TIMEOUT")); err == nil {
+		heliusOptions = append(heliusOptions, clients.WithHeliusTimeout(heliusTimeout))
+	}
+	hc := clients.NewHeliusClient(heliusAPIKey, heliusWebhookID, heliusOptions...)
+	routers.NewMonitoredWalletsRouter(db.GetDB().Database("solana").Collection("monitoredWallets"), v1, hc, walletNames, services.NewPnLService(transactionsCollection, priceSource))
+	sr := routers.NewScannerRouter(rpcClient, hc)
 	sr.SetupRoutes(v1)
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	routers.StartWebSocketManager()
+	socketSendQueueSize, _ := strconv.Atoi(os.Getenv("WEBSOCKET_SEND_QUEUE_SIZE"))
+	routers.StartWebSocketManager(socketSendQueueSize, os.Getenv("WEBSOCKET_SLOW_CONSUMER_POLICY"))
+
+	webhookWorkers, _ := strconv.Atoi(os.Getenv("WEBHOOK_WORKERS"))
+	webhookQueueSize, _ := strconv.Atoi(os.Getenv("WEBHOOK_QUEUE_SIZE"))
+	routers.StartWebhookPipeline(webhookWorkers, webhookQueueSize, os.Getenv("WEBHOOK_QUEUE_FULL_POLICY"))
 
 }
 
 func main() {
 	loadEnv()
+	if err := utils.CheckJWTSecret(); err != nil {
+		logger.Error("Error loading the JWT secret", "error", err)
+		panic(err)
+	}
 
 	port := os.Getenv("PORT")
 	logger.Info("Starting server on port " + port)
@@ -106,7 +191,7 @@ func main() {
 
 	err := router.Run(":" + port)
 	if err != nil {
-		logger.Error("Error starting server", err)
+		logger.Error("Error starting server", "error", err)
 		panic(err)
 	}
 }
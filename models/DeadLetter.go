@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// DeadLetter is a webhook transaction that could not be parsed, kept so parser work can be prioritized
+type DeadLetter struct {
+	Signature   string    `json:"signature" bson:"signature"`
+	Type        string    `json:"type" bson:"type"`
+	Source      string    `json:"source" bson:"source"`
+	Reason      string    `json:"reason" bson:"reason"`
+	Error       string    `json:"error" bson:"error"`
+	FeePayer    string    `json:"feePayer" bson:"feePayer"`
+	Description string    `json:"description" bson:"description"`
+	Timestamp   int64     `json:"timestamp" bson:"timestamp"`
+	FirstSeen   time.Time `json:"firstSeen" bson:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen" bson:"lastSeen"`
+	Attempts    int       `json:"attempts" bson:"attempts"`
+}
+
+// DeadLetterCount is the number of dead letters sharing a failure reason, transaction type and source
+type DeadLetterCount struct {
+	Reason string `json:"reason" bson:"reason"`
+	Type   string `json:"type" bson:"type"`
+	Source string `json:"source" bson:"source"`
+	Count  int64  `json:"count" bson:"count"`
+}
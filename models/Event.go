@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	EventTypeSwap     = "swap"
+	EventTypeTransfer = "transfer"
+)
+
+// Event is a parsed transaction that is cached, persisted and broadcast. The JSON of every event carries its
+// type in the eventType field so consumers can tell them apart.
+type Event interface {
+	GetID() int64
+	// WithID returns a copy of the event with the given ID
+	WithID(ID int64) Event
+	// WithUSDValues returns a copy of the event with its amounts valued in USD
+	WithUSDValues(prices PriceSource) Event
+	GetEventType() string
+	GetSignature() string
+	// GetAccounts returns the public keys of the accounts the event moves funds of
+	GetAccounts() []string
+	// GetAccountNames returns the names of the monitored wallets among its accounts
+	GetAccountNames() []string
+	GetMints() []string
+}
+
+// DecodeEvent decodes the JSON of an event of any type. Swaps stored before events had a type carry none.
+func DecodeEvent(data []byte) (Event, error) {
+	var header struct {
+		EventType string `json:"eventType"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	switch header.EventType {
+	case EventTypeSwap, "":
+		var transaction TransactionDetails
+		err := json.Unmarshal(data, &transaction)
+		return transaction, err
+	case EventTypeTransfer:
+		var transfer TransferDetails
+		err := json.Unmarshal(data, &transfer)
+		return transfer, err
+	default:
+		return nil, fmt.Errorf("unsupported event type %s", header.EventType)
+	}
+}
+
+// appendUnique appends the values that are not empty and not yet in the list
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -4,3 +4,10 @@ type MonitoredWallet struct {
 	PublicKey string `bson:"publicKey"`
 	Name      string `bson:"name"`
 }
+
+// WalletNameResolver names the public keys of monitored wallets without going to the database
+type WalletNameResolver interface {
+	// WalletName returns the name of the monitored wallet with the public key, or an empty string if the key is
+	// not monitored
+	WalletName(publicKey string) string
+}
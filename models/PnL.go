@@ -0,0 +1,45 @@
+package models
+
+// WalletPnL is the profit and loss of the swaps of a wallet. Amounts are decimal strings, SOL amounts in SOL.
+// USD amounts only add up the trades that could be valued in USD, USDComplete tells whether that were all of them.
+type WalletPnL struct {
+	Account       string     `json:"account"`
+	Name          string     `json:"name"`
+	Method        string     `json:"method"`
+	From          int64      `json:"from"`
+	To            int64      `json:"to"`
+	Trades        int        `json:"trades"`
+	ClosedTrades  int        `json:"closedTrades"`
+	Wins          int        `json:"wins"`
+	WinRate       float64    `json:"winRate"`
+	RealizedSOL   string     `json:"realizedSol"`
+	RealizedUSD   string     `json:"realizedUsd"`
+	UnrealizedSOL string     `json:"unrealizedSol,omitempty"`
+	UnrealizedUSD string     `json:"unrealizedUsd,omitempty"`
+	USDComplete   bool       `json:"usdComplete"`
+	OpenPositions int        `json:"openPositions"`
+	Tokens        []TokenPnL `json:"tokens"`
+}
+
+// TokenPnL is the profit and loss of the trades of a wallet in one token and the position it still holds.
+// Quantities sold without a matching buy have no known cost and are left out of the realized PnL.
+type TokenPnL struct {
+	Mint               string `json:"mint"`
+	Symbol             string `json:"symbol"`
+	Bought             string `json:"bought"`
+	Sold               string `json:"sold"`
+	UnmatchedSold      string `json:"unmatchedSold"`
+	ClosedTrades       int    `json:"closedTrades"`
+	Wins               int    `json:"wins"`
+	RealizedSOL        string `json:"realizedSol"`
+	RealizedUSD        string `json:"realizedUsd"`
+	USDComplete        bool   `json:"usdComplete"`
+	Position           string `json:"position"`
+	CostBasisSOL       string `json:"costBasisSol"`
+	CostBasisUSD       string `json:"costBasisUsd,omitempty"`
+	AverageCostSOL     string `json:"averageCostSol,omitempty"`
+	UnrealizedSOL      string `json:"unrealizedSol,omitempty"`
+	UnrealizedUSD      string `json:"unrealizedUsd,omitempty"`
+	CurrentPriceUSD    string `json:"currentPriceUsd,omitempty"`
+	LastTradeTimeStamp int64  `json:"lastTradeTimeStamp"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "solana/utils"
+
+// USDDecimals is the number of decimals USD values are rounded to
+const USDDecimals = 2
+
+// PriceSource prices tokens in USD
+type PriceSource interface {
+	// PriceUSD returns the USD price of one whole token of the mint as a decimal string, or false when the price
+	// is not known
+	PriceUSD(mint string) (string, bool)
+}
+
+// decimalAmount converts a raw amount into a decimal string, or an empty string when the amount is not a valid
+// integer
+func decimalAmount(raw string, decimals int) string {
+	if raw == "" {
+		return ""
+	}
+	amount, err := utils.FormatUnits(raw, decimals)
+	if err != nil {
+		logger.Debug("Invalid raw amount", "amount", raw, "error", err)
+		return ""
+	}
+	return amount
+}
+
+// usdValue values the decimal amount of the mint in USD, or returns an empty string when it cannot be priced
+func usdValue(prices PriceSource, mint, amount string) string {
+	if prices == nil || amount == "" {
+		return ""
+	}
+	price, ok := prices.PriceUSD(mint)
+	if !ok {
+		return ""
+	}
+	value, err := utils.MultiplyDecimal(amount, price, USDDecimals)
+	if err != nil {
+		logger.Debug("Invalid USD price", "mint", mint, "price", price, "error", err)
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"solana/utils"
+	"time"
+)
+
+// RawPayload is a webhook transaction archived as delivered by Helius, with the outcome of its last parse
+type RawPayload struct {
+	Signature  string    `json:"signature" bson:"signature"`
+	Type       string    `json:"type" bson:"type"`
+	Timestamp  int64     `json:"timestamp" bson:"timestamp"`
+	ReceivedAt time.Time `json:"receivedAt" bson:"receivedAt"`
+	ParsedAt   time.Time `json:"parsedAt" bson:"parsedAt"`
+	Outcome    string    `json:"outcome" bson:"outcome"`
+	Error      string    `json:"error" bson:"error"`
+	// Payload is the gzip compressed JSON of the transaction
+	Payload []byte `json:"-" bson:"payload"`
+}
+
+// Decode decompresses and decodes the archived transaction
+func (r *RawPayload) Decode() (SolanaPayload, error) {
+	var payload SolanaPayload
+	data, err := utils.Decompress(r.Payload)
+	if err != nil {
+		return payload, err
+	}
+	err = json.Unmarshal(data, &payload)
+	return payload, err
+}
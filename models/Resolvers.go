@@ -0,0 +1,26 @@
+package models
+
+// Resolvers look up what a payload does not carry itself. Either resolver may be nil.
+type Resolvers struct {
+	Names  WalletNameResolver
+	Tokens TokenResolver
+}
+
+func (r Resolvers) walletName(publicKey string) string {
+	if r.Names == nil || publicKey == "" {
+		return ""
+	}
+	return r.Names.WalletName(publicKey)
+}
+
+// tokenSymbol returns the symbol of the mint, or an empty string when it is not known
+func (r Resolvers) tokenSymbol(mint string) string {
+	if r.Tokens == nil || mint == "" {
+		return ""
+	}
+	token, ok := r.Tokens.LookupToken(mint)
+	if !ok {
+		return ""
+	}
+	return token.Symbol
+}
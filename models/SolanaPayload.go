@@ -1,15 +1,46 @@
 package models
 
 import (
-	"fmt"
-	"solana/db"
+	"errors"
 	"solana/utils"
 	"strconv"
 	"strings"
 )
 
+var (
+	ErrToTokenNotFound   = errors.New("to token was not found")
+	ErrFromTokenNotFound = errors.New("from token was not found")
+	ErrSameToken         = errors.New("from token and to token are the same")
+	ErrNoTransfers       = errors.New("no transfers were found")
+)
+
+const (
+	FailureToTokenNotFound   = "to_token_not_found"
+	FailureFromTokenNotFound = "from_token_not_found"
+	FailureSameToken         = "same_token"
+	FailureNoTransfers       = "no_transfers"
+	FailureOther             = "other"
+)
+
+// FailureReason maps an error returned while parsing a payload to a stable reason code
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrToTokenNotFound):
+		return FailureToTokenNotFound
+	case errors.Is(err, ErrFromTokenNotFound):
+		return FailureFromTokenNotFound
+	case errors.Is(err, ErrSameToken):
+		return FailureSameToken
+	case errors.Is(err, ErrNoTransfers):
+		return FailureNoTransfers
+	default:
+		return FailureOther
+	}
+}
+
 type SolanaPayload struct {
 	Type             string               `bson:"type"`
+	Source           string               `bson:"source"`
 	Description      string               `bson:"description"`
 	Events           map[string]SwapEvent `bson:"events"`
 	Fee              int64                `bson:"fee"`
@@ -18,6 +49,14 @@ type SolanaPayload struct {
 	Timestamp        int64                `bson:"timestamp"`
 	TransactionError string               `bson:"transactionError"`
 	AccountData      []AccountData        `bson:"accountData"`
+	NativeTransfers  []NativeTransfer     `bson:"nativeTransfers"`
+	TokenTransfers   []TokenIO            `bson:"tokenTransfers"`
+}
+
+type NativeTransfer struct {
+	FromUserAccount string `json:"fromUserAccount"`
+	ToUserAccount   string `json:"toUserAccount"`
+	Amount          int64  `json:"amount"`
 }
 
 type SwapEvent struct {
@@ -74,122 +113,99 @@ type RawTokenAmount struct {
 	TokenAmount string `json:"tokenAmount"`
 }
 
-func (s *SolanaPayload) GetTransactionDetails(ID int64) (TransactionDetails, error) {
-	var FromToken string
-	var ToToken string
-	var AmountIn string
-	var AmountOut string
-	var FromTokenDecimal int
-	var ToTokenDecimal int
-	var fromTokenSymbol string
-	var toTokenSymbol string
+// SwapSide is one side of a swap as seen from the fee payer, the token it gave away or the one it received
+type SwapSide struct {
+	Mint     string
+	Amount   string
+	Decimals int
+	Symbol   string
+}
 
-	for _, accountData := range s.AccountData {
-		if accountData.Account == s.FeePayer {
-			if accountData.NativeBalanceChange < 0 {
-				FromToken = utils.SOL_ADDRESS
-				AmountOut = strconv.FormatInt(accountData.NativeBalanceChange*-1, 10)
-				fromTokenSymbol = "SOL"
-			} else if accountData.NativeBalanceChange > 0 {
-				ToToken = utils.SOL_ADDRESS
-				AmountIn = strconv.FormatInt(accountData.NativeBalanceChange, 10)
-				toTokenSymbol = "SOL"
-			}
-		}
-		if accountData.TokenBalanceChanges != nil && len(accountData.TokenBalanceChanges) > 0 {
-			for _, tokenBalance := range accountData.TokenBalanceChanges {
-				if tokenBalance.UserAccount == s.FeePayer {
-					if tokenBalance.RawTokenAmount.TokenAmount[0] == '-' {
-						FromToken = tokenBalance.Mint
-						AmountOut = tokenBalance.RawTokenAmount.TokenAmount[1:]
-						FromTokenDecimal = tokenBalance.RawTokenAmount.Decimals
-					} else {
-						ToToken = tokenBalance.Mint
-						AmountIn = tokenBalance.RawTokenAmount.TokenAmount
-						ToTokenDecimal = tokenBalance.RawTokenAmount.Decimals
-					}
-				}
-			}
+// FeePayerBalanceSides derives the swap sides from the net balance changes of the fee payer. A decrease is the
+// token given away and an increase the token received. Token changes take precedence over SOL, whose balance
+// also pays the fees, and when several tokens moved the same way the first one is taken.
+func (s *SolanaPayload) FeePayerBalanceSides() (from SwapSide, to SwapSide) {
+	for _, change := range s.FeePayerNetEffect() {
+		side := SwapSide{Mint: change.Mint, Amount: strings.TrimPrefix(change.Amount, "-"), Decimals: change.Decimals, Symbol: change.Symbol}
+		negative := strings.HasPrefix(change.Amount, "-")
+		if negative && (from.Mint == "" || from.Mint == utils.SOL_ADDRESS) {
+			from = side
+		} else if !negative && (to.Mint == "" || to.Mint == utils.SOL_ADDRESS) {
+			to = side
 		}
 	}
+	return from, to
+}
 
-	if FromToken == "" {
-		if len(s.Events["swap"].TokenInputs) > 0 {
-			FromToken = s.Events["swap"].TokenInputs[0].Mint
-			AmountIn = s.Events["swap"].TokenInputs[0].RawTokenAmount.TokenAmount
-			FromTokenDecimal = s.Events["swap"].TokenInputs[0].RawTokenAmount.Decimals
-		} else if len(s.Events["swap"].InnerSwaps) > 0 && len(s.Events["swap"].InnerSwaps[0].TokenInputs) > 0 {
-			FromToken = s.Events["swap"].InnerSwaps[0].TokenInputs[0].Mint
-			AmountIn = s.Events["swap"].InnerSwaps[0].TokenInputs[0].RawTokenAmount.TokenAmount
-			FromTokenDecimal = s.Events["swap"].InnerSwaps[0].TokenInputs[0].RawTokenAmount.Decimals
-		}
+// SwapEventSides derives the swap sides from the token inputs and outputs of the swap event, falling back to
+// the first inner swap when the event has none
+func (s *SolanaPayload) SwapEventSides() (from SwapSide, to SwapSide) {
+	swap := s.Events["swap"]
+	if len(swap.TokenInputs) > 0 {
+		from = swap.TokenInputs[0].SwapSide()
+	} else if len(swap.InnerSwaps) > 0 && len(swap.InnerSwaps[0].TokenInputs) > 0 {
+		from = swap.InnerSwaps[0].TokenInputs[0].SwapSide()
 	}
-
-	if ToToken == "" {
-		if len(s.Events["swap"].TokenOutputs) > 0 {
-			ToToken = s.Events["swap"].TokenOutputs[0].Mint
-			AmountOut = s.Events["swap"].TokenOutputs[0].RawTokenAmount.TokenAmount
-			ToTokenDecimal = s.Events["swap"].TokenOutputs[0].RawTokenAmount.Decimals
-		} else if len(s.Events["swap"].InnerSwaps) > 0 && len(s.Events["swap"].InnerSwaps[0].TokenOutputs) > 0 {
-			ToToken = s.Events["swap"].InnerSwaps[0].TokenOutputs[0].Mint
-			AmountOut = s.Events["swap"].InnerSwaps[0].TokenOutputs[0].RawTokenAmount.TokenAmount
-			ToTokenDecimal = s.Events["swap"].InnerSwaps[0].TokenOutputs[0].RawTokenAmount.Decimals
-		}
+	if len(swap.TokenOutputs) > 0 {
+		to = swap.TokenOutputs[0].SwapSide()
+	} else if len(swap.InnerSwaps) > 0 && len(swap.InnerSwaps[0].TokenOutputs) > 0 {
+		to = swap.InnerSwaps[0].TokenOutputs[0].SwapSide()
 	}
+	return from, to
+}
 
-	result := db.GetDB().Database("solana").Collection("monitoredWallets").FindOne(nil, map[string]interface{}{"publicKey": s.FeePayer})
-	var walletName string
-	if result.Err() != nil {
-		fmt.Printf("error finding wallet %s", result.Err())
-		walletName = ""
-	} else {
-		var wallet MonitoredWallet
-		err := result.Decode(&wallet)
-		if err != nil {
-			return TransactionDetails{}, fmt.Errorf("error decoding wallet %s", err)
-		}
-		walletName = wallet.Name
-	}
+// SwapSide returns the token and raw amount moved by the transfer as a swap side
+func (t TokenIO) SwapSide() SwapSide {
+	return SwapSide{Mint: t.Mint, Amount: t.RawTokenAmount.TokenAmount, Decimals: t.RawTokenAmount.Decimals}
+}
 
-	if ToToken == "" {
+// NewTransactionDetails validates the swap sides found by a parser and builds the transaction details from them.
+// Whichever parser found them, the amount given away is reported as AmountOut and the amount received as
+// AmountIn. Sides without a symbol get the one of the token metadata.
+func (s *SolanaPayload) NewTransactionDetails(ID int64, from SwapSide, to SwapSide, resolvers Resolvers) (TransactionDetails, error) {
+	if to.Mint == "" {
 		logger.Debug("ToToken was not found", "signature", s.Signature, "description", s.Description)
-		return TransactionDetails{}, fmt.Errorf("to token was not found")
+		return TransactionDetails{}, ErrToTokenNotFound
 	}
-	if FromToken == "" {
+	if from.Mint == "" {
 		logger.Debug("FromToken was not found", "signature", s.Signature, "description", s.Description)
-		return TransactionDetails{}, fmt.Errorf("from token was not found")
+		return TransactionDetails{}, ErrFromTokenNotFound
 	}
-	if FromToken == ToToken {
+	if from.Mint == to.Mint {
 		logger.Debug("FromToken and ToToken are the same", "signature", s.Signature, "description", s.Description)
-		return TransactionDetails{}, fmt.Errorf("from token and to token are the same")
+		return TransactionDetails{}, ErrSameToken
 	}
 
-	if strings.Trim(s.Description, " ") != "" {
-		splitString := strings.Split(s.Description, " ")
-		if len(splitString) > 3 {
-			if fromTokenSymbol == "" {
-				fromTokenSymbol = splitString[3]
-			}
-		}
-		if len(splitString) > 6 {
-			if toTokenSymbol == "" {
-				toTokenSymbol = splitString[6]
-			}
+	if from.Symbol == "" {
+		from.Symbol = resolvers.tokenSymbol(from.Mint)
+	}
+	if to.Symbol == "" {
+		to.Symbol = resolvers.tokenSymbol(to.Mint)
+	}
+	netEffect := s.FeePayerNetEffect()
+	for i := range netEffect {
+		if netEffect[i].Symbol == "" {
+			netEffect[i].Symbol = resolvers.tokenSymbol(netEffect[i].Mint)
 		}
 	}
 	return TransactionDetails{
 		ID:               ID,
+		EventType:        EventTypeSwap,
 		Account:          s.FeePayer,
-		AccountName:      walletName,
+		AccountName:      resolvers.walletName(s.FeePayer),
 		Signature:        s.Signature,
-		FromToken:        FromToken,
-		FromTokenSymbol:  fromTokenSymbol,
-		FromTokenDecimal: FromTokenDecimal,
-		ToToken:          ToToken,
-		ToTokenSymbol:    toTokenSymbol,
-		ToTokenDecimal:   ToTokenDecimal,
-		AmountIn:         AmountIn,
-		AmountOut:        AmountOut,
+		FromToken:        from.Mint,
+		FromTokenSymbol:  from.Symbol,
+		FromTokenDecimal: from.Decimals,
+		ToToken:          to.Mint,
+		ToTokenSymbol:    to.Symbol,
+		ToTokenDecimal:   to.Decimals,
+		AmountIn:         to.Amount,
+		AmountOut:        from.Amount,
+		AmountInDecimal:  decimalAmount(to.Amount, to.Decimals),
+		AmountOutDecimal: decimalAmount(from.Amount, from.Decimals),
+		Legs:             s.SwapLegs(),
+		NetEffect:        netEffect,
 		TimeStamp:        s.Timestamp,
 		Status:           "confirmed",
 		Fees:             s.Fee,
@@ -197,3 +213,90 @@ func (s *SolanaPayload) GetTransactionDetails(ID int64) (TransactionDetails, err
 		Description:      s.Description,
 	}, nil
 }
+
+// Transfers returns the SOL and token transfers of the payload that moved a non-zero amount. Token amounts are
+// raw amounts taken from the balance change of the receiving token account. When the payload lacks it only the
+// decimal amount is set.
+func (s *SolanaPayload) Transfers() []Transfer {
+	transfers := make([]Transfer, 0, len(s.NativeTransfers)+len(s.TokenTransfers))
+	for _, nativeTransfer := range s.NativeTransfers {
+		if nativeTransfer.Amount == 0 {
+			continue
+		}
+		amount := strconv.FormatInt(nativeTransfer.Amount, 10)
+		transfers = append(transfers, Transfer{
+			From:          nativeTransfer.FromUserAccount,
+			To:            nativeTransfer.ToUserAccount,
+			Mint:          utils.SOL_ADDRESS,
+			Symbol:        "SOL",
+			Amount:        amount,
+			Decimals:      utils.SOL_DECIMALS,
+			AmountDecimal: decimalAmount(amount, utils.SOL_DECIMALS),
+		})
+	}
+	for _, tokenTransfer := range s.TokenTransfers {
+		if tokenTransfer.TokenAmount == 0 {
+			continue
+		}
+		// Without the raw amount only the decimal amount Helius reports is known, Amount stays empty rather than
+		// holding a decimal amount
+		transfer := Transfer{
+			From:          tokenTransfer.FromUserAccount,
+			To:            tokenTransfer.ToUserAccount,
+			Mint:          tokenTransfer.Mint,
+			AmountDecimal: strconv.FormatFloat(tokenTransfer.TokenAmount, 'f', -1, 64),
+		}
+		if raw, ok := s.receivedRawAmount(tokenTransfer.ToTokenAccount, tokenTransfer.Mint); ok {
+			transfer.Amount = raw.TokenAmount
+			transfer.Decimals = raw.Decimals
+			transfer.AmountDecimal = decimalAmount(raw.TokenAmount, raw.Decimals)
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers
+}
+
+func (s *SolanaPayload) receivedRawAmount(tokenAccount, mint string) (RawTokenAmount, bool) {
+	for _, accountData := range s.AccountData {
+		if accountData.Account != tokenAccount {
+			continue
+		}
+		for _, tokenBalance := range accountData.TokenBalanceChanges {
+			amount := tokenBalance.RawTokenAmount.TokenAmount
+			if tokenBalance.Mint == mint && amount != "" && amount[0] != '-' {
+				return tokenBalance.RawTokenAmount, true
+			}
+		}
+	}
+	return RawTokenAmount{}, false
+}
+
+// NewTransferDetails builds the transfer details of the payload from the transfers found by a parser
+func (s *SolanaPayload) NewTransferDetails(ID int64, transfers []Transfer, resolvers Resolvers) (TransferDetails, error) {
+	if len(transfers) == 0 {
+		logger.Debug("No transfers were found", "signature", s.Signature, "description", s.Description)
+		return TransferDetails{}, ErrNoTransfers
+	}
+
+	for i := range transfers {
+		transfers[i].FromName = resolvers.walletName(transfers[i].From)
+		transfers[i].ToName = resolvers.walletName(transfers[i].To)
+		if transfers[i].Symbol == "" {
+			transfers[i].Symbol = resolvers.tokenSymbol(transfers[i].Mint)
+		}
+	}
+
+	return TransferDetails{
+		ID:          ID,
+		EventType:   EventTypeTransfer,
+		Account:     s.FeePayer,
+		AccountName: resolvers.walletName(s.FeePayer),
+		Signature:   s.Signature,
+		Transfers:   transfers,
+		TimeStamp:   s.Timestamp,
+		Status:      "confirmed",
+		Fees:        s.Fee,
+		Error:       s.TransactionError,
+		Description: s.Description,
+	}, nil
+}
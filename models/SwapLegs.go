@@ -0,0 +1,123 @@
+package models
+
+import (
+	"math/big"
+	"solana/utils"
+	"strconv"
+)
+
+// SwapLeg is a single hop of a routed swap, amounts are decimal amounts
+type SwapLeg struct {
+	Program      string `json:"program" bson:"program"`
+	Source       string `json:"source" bson:"source"`
+	InputMint    string `json:"inputMint" bson:"inputMint"`
+	InputAmount  string `json:"inputAmount" bson:"inputAmount"`
+	OutputMint   string `json:"outputMint" bson:"outputMint"`
+	OutputAmount string `json:"outputAmount" bson:"outputAmount"`
+}
+
+// BalanceChange is the net change of the balance of one token, negative when the token was given away
+type BalanceChange struct {
+	Mint          string `json:"mint" bson:"mint"`
+	Symbol        string `json:"symbol" bson:"symbol"`
+	Amount        string `json:"amount" bson:"amount"`
+	Decimals      int    `json:"decimals" bson:"decimals"`
+	AmountDecimal string `json:"amountDecimal" bson:"amountDecimal"`
+}
+
+// DecimalAmount returns the amount moved as a decimal string, from the raw amount when the payload has it
+func (t TokenIO) DecimalAmount() string {
+	if t.RawTokenAmount.TokenAmount != "" {
+		return decimalAmount(t.RawTokenAmount.TokenAmount, t.RawTokenAmount.Decimals)
+	}
+	return strconv.FormatFloat(t.TokenAmount, 'f', -1, 64)
+}
+
+// SwapLegs returns the hops of the swap event in execution order. A swap event without inner swaps is a
+// single leg executed by the source of the payload.
+func (s *SolanaPayload) SwapLegs() []SwapLeg {
+	swap := s.Events["swap"]
+	legs := make([]SwapLeg, 0, len(swap.InnerSwaps))
+	for _, innerSwap := range swap.InnerSwaps {
+		leg, ok := newSwapLeg(innerSwap.TokenInputs, innerSwap.TokenOutputs)
+		if !ok {
+			continue
+		}
+		leg.Program = innerSwap.ProgramInfo.ProgramName
+		leg.Source = innerSwap.ProgramInfo.Source
+		legs = append(legs, leg)
+	}
+	if len(legs) == 0 {
+		if leg, ok := newSwapLeg(swap.TokenInputs, swap.TokenOutputs); ok {
+			leg.Program = s.Source
+			leg.Source = s.Source
+			legs = append(legs, leg)
+		}
+	}
+	return legs
+}
+
+// newSwapLeg builds a leg from the first input and the last output of a hop
+func newSwapLeg(inputs []TokenIO, outputs []TokenIO) (SwapLeg, bool) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return SwapLeg{}, false
+	}
+	input, output := inputs[0], outputs[len(outputs)-1]
+	return SwapLeg{
+		InputMint:    input.Mint,
+		InputAmount:  input.DecimalAmount(),
+		OutputMint:   output.Mint,
+		OutputAmount: output.DecimalAmount(),
+	}, true
+}
+
+// FeePayerNetEffect sums the balance changes of the fee payer per mint, in the order the mints first appear.
+// Native SOL and wrapped SOL are counted as the same token and mints that net out to zero are left out.
+func (s *SolanaPayload) FeePayerNetEffect() []BalanceChange {
+	mints := make([]string, 0)
+	totals := make(map[string]*big.Int)
+	decimals := make(map[string]int)
+	add := func(mint string, amount string, mintDecimals int) {
+		value, ok := new(big.Int).SetString(amount, 10)
+		if !ok {
+			return
+		}
+		if _, found := totals[mint]; !found {
+			mints = append(mints, mint)
+			totals[mint] = new(big.Int)
+		}
+		totals[mint].Add(totals[mint], value)
+		decimals[mint] = mintDecimals
+	}
+
+	for _, accountData := range s.AccountData {
+		if accountData.Account == s.FeePayer && accountData.NativeBalanceChange != 0 {
+			add(utils.SOL_ADDRESS, strconv.FormatInt(accountData.NativeBalanceChange, 10), utils.SOL_DECIMALS)
+		}
+		for _, tokenBalance := range accountData.TokenBalanceChanges {
+			if tokenBalance.UserAccount == s.FeePayer {
+				add(tokenBalance.Mint, tokenBalance.RawTokenAmount.TokenAmount, tokenBalance.RawTokenAmount.Decimals)
+			}
+		}
+	}
+
+	changes := make([]BalanceChange, 0, len(mints))
+	for _, mint := range mints {
+		if totals[mint].Sign() == 0 {
+			continue
+		}
+		amount := totals[mint].String()
+		symbol := ""
+		if mint == utils.SOL_ADDRESS {
+			symbol = "SOL"
+		}
+		changes = append(changes, BalanceChange{
+			Mint:          mint,
+			Symbol:        symbol,
+			Amount:        amount,
+			Decimals:      decimals[mint],
+			AmountDecimal: decimalAmount(amount, decimals[mint]),
+		})
+	}
+	return changes
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Token metadata sources, from most to least trusted
+const (
+	TokenSourceSeed     = "seed"
+	TokenSourceMetaplex = "metaplex"
+)
+
+// TokenMetadata describes the token of a mint. URI is the off-chain metadata document, the logo is read from it
+// on request.
+type TokenMetadata struct {
+	Mint      string    `json:"mint" bson:"mint"`
+	Symbol    string    `json:"symbol" bson:"symbol"`
+	Name      string    `json:"name" bson:"name"`
+	Decimals  int       `json:"decimals" bson:"decimals"`
+	Logo      string    `json:"logo" bson:"logo"`
+	URI       string    `json:"uri,omitempty" bson:"uri,omitempty"`
+	Source    string    `json:"source" bson:"source"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// TokenResolver looks up the metadata of mints
+type TokenResolver interface {
+	// LookupToken returns the metadata of the mint, or false when it is not known
+	LookupToken(mint string) (TokenMetadata, bool)
+}
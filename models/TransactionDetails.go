@@ -1,21 +1,66 @@
 package models
 
+// TransactionDetails is a swap of the account. AmountOut is what it gave away of FromToken and AmountIn what it
+// received of ToToken, whichever parser read the swap.
 type TransactionDetails struct {
-	ID               int64  `json:"id"`
-	Account          string `json:"account"`
-	AccountName      string `json:"accountName"`
-	Signature        string `json:"signature"`
-	FromToken        string `json:"fromToken"`
-	FromTokenSymbol  string `json:"fromTokenSymbol"`
-	FromTokenDecimal int    `json:"fromTokenDecimal"`
-	ToToken          string `json:"toToken"`
-	ToTokenSymbol    string `json:"toTokenSymbol"`
-	ToTokenDecimal   int    `json:"toTokenDecimal"`
-	AmountIn         string `json:"amountIn"`
-	AmountOut        string `json:"amountOut"`
-	TimeStamp        int64  `json:"timeStamp"`
-	Status           string `json:"status"`
-	Fees             int64  `json:"fees"`
-	Error            string `json:"error"`
-	Description      string `json:"description"`
+	ID               int64           `json:"id" bson:"id"`
+	EventType        string          `json:"eventType" bson:"eventType"`
+	Account          string          `json:"account" bson:"account"`
+	AccountName      string          `json:"accountName" bson:"accountName"`
+	Signature        string          `json:"signature" bson:"signature"`
+	FromToken        string          `json:"fromToken" bson:"fromToken"`
+	FromTokenSymbol  string          `json:"fromTokenSymbol" bson:"fromTokenSymbol"`
+	FromTokenDecimal int             `json:"fromTokenDecimal" bson:"fromTokenDecimal"`
+	ToToken          string          `json:"toToken" bson:"toToken"`
+	ToTokenSymbol    string          `json:"toTokenSymbol" bson:"toTokenSymbol"`
+	ToTokenDecimal   int             `json:"toTokenDecimal" bson:"toTokenDecimal"`
+	AmountIn         string          `json:"amountIn" bson:"amountIn"`
+	AmountOut        string          `json:"amountOut" bson:"amountOut"`
+	AmountInDecimal  string          `json:"amountInDecimal" bson:"amountInDecimal"`
+	AmountOutDecimal string          `json:"amountOutDecimal" bson:"amountOutDecimal"`
+	AmountInUSD      string          `json:"amountInUsd,omitempty" bson:"amountInUsd,omitempty"`
+	AmountOutUSD     string          `json:"amountOutUsd,omitempty" bson:"amountOutUsd,omitempty"`
+	Legs             []SwapLeg       `json:"legs" bson:"legs"`
+	NetEffect        []BalanceChange `json:"netEffect" bson:"netEffect"`
+	TimeStamp        int64           `json:"timeStamp" bson:"timeStamp"`
+	Status           string          `json:"status" bson:"status"`
+	Fees             int64           `json:"fees" bson:"fees"`
+	Error            string          `json:"error" bson:"error"`
+	Description      string          `json:"description" bson:"description"`
+}
+
+func (t TransactionDetails) GetID() int64 {
+	return t.ID
+}
+
+func (t TransactionDetails) WithID(ID int64) Event {
+	t.ID = ID
+	return t
+}
+
+// WithUSDValues returns a copy of the transaction with both amounts valued in USD by the price source
+func (t TransactionDetails) WithUSDValues(prices PriceSource) Event {
+	t.AmountInUSD = usdValue(prices, t.ToToken, t.AmountInDecimal)
+	t.AmountOutUSD = usdValue(prices, t.FromToken, t.AmountOutDecimal)
+	return t
+}
+
+func (t TransactionDetails) GetEventType() string {
+	return EventTypeSwap
+}
+
+func (t TransactionDetails) GetSignature() string {
+	return t.Signature
+}
+
+func (t TransactionDetails) GetAccounts() []string {
+	return appendUnique(nil, t.Account)
+}
+
+func (t TransactionDetails) GetAccountNames() []string {
+	return appendUnique(nil, t.AccountName)
+}
+
+func (t TransactionDetails) GetMints() []string {
+	return appendUnique(nil, t.FromToken, t.ToToken)
 }
@@ -0,0 +1,82 @@
+package models
+
+// TransferDetails are the SOL and token transfers made in a single transaction
+type TransferDetails struct {
+	ID          int64      `json:"id" bson:"id"`
+	EventType   string     `json:"eventType" bson:"eventType"`
+	Account     string     `json:"account" bson:"account"`
+	AccountName string     `json:"accountName" bson:"accountName"`
+	Signature   string     `json:"signature" bson:"signature"`
+	Transfers   []Transfer `json:"transfers" bson:"transfers"`
+	TimeStamp   int64      `json:"timeStamp" bson:"timeStamp"`
+	Status      string     `json:"status" bson:"status"`
+	Fees        int64      `json:"fees" bson:"fees"`
+	Error       string     `json:"error" bson:"error"`
+	Description string     `json:"description" bson:"description"`
+}
+
+// Transfer is a single movement of SOL or a token between two accounts. Names are only set for monitored wallets.
+type Transfer struct {
+	From          string `json:"from" bson:"from"`
+	FromName      string `json:"fromName" bson:"fromName"`
+	To            string `json:"to" bson:"to"`
+	ToName        string `json:"toName" bson:"toName"`
+	Mint          string `json:"mint" bson:"mint"`
+	Symbol        string `json:"symbol" bson:"symbol"`
+	Amount        string `json:"amount" bson:"amount"`
+	Decimals      int    `json:"decimals" bson:"decimals"`
+	AmountDecimal string `json:"amountDecimal" bson:"amountDecimal"`
+	AmountUSD     string `json:"amountUsd,omitempty" bson:"amountUsd,omitempty"`
+}
+
+func (t TransferDetails) GetID() int64 {
+	return t.ID
+}
+
+func (t TransferDetails) WithID(ID int64) Event {
+	t.ID = ID
+	return t
+}
+
+// WithUSDValues returns a copy of the transfer details with every transfer valued in USD by the price source
+func (t TransferDetails) WithUSDValues(prices PriceSource) Event {
+	transfers := make([]Transfer, len(t.Transfers))
+	for i, transfer := range t.Transfers {
+		transfer.AmountUSD = usdValue(prices, transfer.Mint, transfer.AmountDecimal)
+		transfers[i] = transfer
+	}
+	t.Transfers = transfers
+	return t
+}
+
+func (t TransferDetails) GetEventType() string {
+	return EventTypeTransfer
+}
+
+func (t TransferDetails) GetSignature() string {
+	return t.Signature
+}
+
+func (t TransferDetails) GetAccounts() []string {
+	accounts := appendUnique(nil, t.Account)
+	for _, transfer := range t.Transfers {
+		accounts = appendUnique(accounts, transfer.From, transfer.To)
+	}
+	return accounts
+}
+
+func (t TransferDetails) GetAccountNames() []string {
+	names := appendUnique(nil, t.AccountName)
+	for _, transfer := range t.Transfers {
+		names = appendUnique(names, transfer.FromName, transfer.ToName)
+	}
+	return names
+}
+
+func (t TransferDetails) GetMints() []string {
+	var mints []string
+	for _, transfer := range t.Transfers {
+		mints = appendUnique(mints, transfer.Mint)
+	}
+	return mints
+}
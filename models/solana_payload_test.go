@@ -2,8 +2,11 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"solana/utils"
 	"testing"
 )
 
@@ -27,3 +30,213 @@ func TestSolanaPayloadUnmarshalling(t *testing.T) {
 		t.Error("Expected non-empty description")
 	}
 }
+
+func TestFailureReason(t *testing.T) {
+	cases := map[error]string{
+		ErrToTokenNotFound:   FailureToTokenNotFound,
+		ErrFromTokenNotFound: FailureFromTokenNotFound,
+		ErrSameToken:         FailureSameToken,
+		ErrNoTransfers:       FailureNoTransfers,
+		fmt.Errorf("parsing swap: %w", ErrSameToken): FailureSameToken,
+		errors.New("error decoding wallet"):          FailureOther,
+	}
+	for err, expected := range cases {
+		if reason := FailureReason(err); reason != expected {
+			t.Errorf("Incorrect reason for %q: got %s want %s", err, reason, expected)
+		}
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	payload := SolanaPayload{
+		NativeTransfers: []NativeTransfer{
+			{FromUserAccount: "alice", ToUserAccount: "bob", Amount: 1500000000},
+			{FromUserAccount: "alice", ToUserAccount: "bob", Amount: 0},
+		},
+		TokenTransfers: []TokenIO{
+			{FromUserAccount: "bob", ToUserAccount: "alice", ToTokenAccount: "aliceUSDC", Mint: "USDC", TokenAmount: 2.5},
+			{FromUserAccount: "bob", ToUserAccount: "carol", ToTokenAccount: "carolBONK", Mint: "BONK", TokenAmount: 1234.5},
+		},
+		AccountData: []AccountData{
+			{Account: "aliceUSDC", TokenBalanceChanges: []TokenBalance{
+				{Mint: "USDC", RawTokenAmount: RawTokenAmount{TokenAmount: "2500000", Decimals: 6}},
+			}},
+		},
+	}
+
+	transfers := payload.Transfers()
+	if len(transfers) != 3 {
+		t.Fatalf("Expected 3 transfers, got %d", len(transfers))
+	}
+	if transfers[0].Mint != utils.SOL_ADDRESS || transfers[0].Amount != "1500000000" || transfers[0].Decimals != utils.SOL_DECIMALS {
+		t.Errorf("Incorrect SOL transfer: %+v", transfers[0])
+	}
+	if transfers[1].Amount != "2500000" || transfers[1].Decimals != 6 {
+		t.Errorf("Incorrect token transfer: %+v", transfers[1])
+	}
+	if transfers[2].Amount != "" || transfers[2].AmountDecimal != "1234.5" {
+		t.Errorf("Expected only the decimal amount of a transfer without raw amount: %+v", transfers[2])
+	}
+}
+
+type walletNames map[string]string
+
+func (w walletNames) WalletName(publicKey string) string {
+	return w[publicKey]
+}
+
+func TestNewTransferDetailsNamesWallets(t *testing.T) {
+	payload := SolanaPayload{FeePayer: "alice", Signature: "sig"}
+	names := walletNames{"alice": "Alice", "bob": "Bob"}
+
+	transferDetails, err := payload.NewTransferDetails(1, []Transfer{{From: "alice", To: "bob"}, {From: "bob", To: "carol"}}, Resolvers{Names: names})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transferDetails.AccountName != "Alice" {
+		t.Errorf("Expected account name Alice, got %q", transferDetails.AccountName)
+	}
+	if transferDetails.Transfers[0].FromName != "Alice" || transferDetails.Transfers[0].ToName != "Bob" {
+		t.Errorf("Incorrect names on first transfer: %+v", transferDetails.Transfers[0])
+	}
+	if transferDetails.Transfers[1].ToName != "" {
+		t.Errorf("Expected unmonitored wallet to stay unnamed, got %q", transferDetails.Transfers[1].ToName)
+	}
+
+	_, err = payload.NewTransferDetails(1, nil, Resolvers{})
+	if !errors.Is(err, ErrNoTransfers) {
+		t.Errorf("Expected ErrNoTransfers, got %v", err)
+	}
+}
+
+type prices map[string]string
+
+func (p prices) PriceUSD(mint string) (string, bool) {
+	price, ok := p[mint]
+	return price, ok
+}
+
+func TestDecimalAmountsAndUSDValues(t *testing.T) {
+	payload := SolanaPayload{
+		FeePayer: "alice",
+		AccountData: []AccountData{
+			{Account: "alice", NativeBalanceChange: -1500000000},
+			{Account: "aliceUSDC", TokenBalanceChanges: []TokenBalance{
+				{Mint: "USDC", UserAccount: "alice", RawTokenAmount: RawTokenAmount{TokenAmount: "213505650", Decimals: 6}},
+			}},
+		},
+	}
+
+	from, to := payload.FeePayerBalanceSides()
+	transactionDetails, err := payload.NewTransactionDetails(1, from, to, Resolvers{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transactionDetails.FromTokenDecimal != utils.SOL_DECIMALS || transactionDetails.AmountOutDecimal != "1.5" {
+		t.Errorf("Incorrect SOL side: %d decimals, amount %s", transactionDetails.FromTokenDecimal, transactionDetails.AmountOutDecimal)
+	}
+	if transactionDetails.AmountInDecimal != "213.50565" {
+		t.Errorf("Incorrect token amount %s", transactionDetails.AmountInDecimal)
+	}
+
+	valued := transactionDetails.WithUSDValues(prices{utils.SOL_ADDRESS: "142.3371"}).(TransactionDetails)
+	if valued.AmountOutUSD != "213.51" {
+		t.Errorf("Incorrect SOL value %s", valued.AmountOutUSD)
+	}
+	if valued.AmountInUSD != "" {
+		t.Errorf("Expected unpriced token to stay unvalued, got %s", valued.AmountInUSD)
+	}
+}
+
+// raydiumSwap is a swap of 1 SOL for 5 TOK read from the swap event, as the RAYDIUM and ORCA parsers do
+func raydiumSwap() SolanaPayload {
+	return SolanaPayload{
+		FeePayer: "alice",
+		Source:   "RAYDIUM",
+		Events: map[string]SwapEvent{"swap": {
+			TokenInputs:  []TokenIO{{Mint: utils.SOL_ADDRESS, RawTokenAmount: RawTokenAmount{TokenAmount: "1000000000", Decimals: utils.SOL_DECIMALS}}},
+			TokenOutputs: []TokenIO{{Mint: "TOK", RawTokenAmount: RawTokenAmount{TokenAmount: "5000000", Decimals: 6}}},
+		}},
+	}
+}
+
+func TestSwapEventUSDValues(t *testing.T) {
+	payload := raydiumSwap()
+	from, to := payload.SwapEventSides()
+	transactionDetails, err := payload.NewTransactionDetails(1, from, to, Resolvers{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	valued := transactionDetails.WithUSDValues(prices{utils.SOL_ADDRESS: "150", "TOK": "0.01"}).(TransactionDetails)
+	if valued.AmountOutDecimal != "1" || valued.AmountOutUSD != "150.00" {
+		t.Errorf("Incorrect SOL side %s worth %s should be 1 worth 150.00", valued.AmountOutDecimal, valued.AmountOutUSD)
+	}
+	if valued.AmountInDecimal != "5" || valued.AmountInUSD != "0.05" {
+		t.Errorf("Incorrect TOK side %s worth %s should be 5 worth 0.05", valued.AmountInDecimal, valued.AmountInUSD)
+	}
+}
+
+type tokens map[string]TokenMetadata
+
+func (t tokens) LookupToken(mint string) (TokenMetadata, bool) {
+	token, ok := t[mint]
+	return token, ok
+}
+
+func TestNewTransactionDetailsResolvesSymbols(t *testing.T) {
+	payload := SolanaPayload{FeePayer: "alice", Description: "alice swapped 1.5 SOL for 213.5 WRONG"}
+	resolvers := Resolvers{Tokens: tokens{"USDC": {Mint: "USDC", Symbol: "USDC"}}}
+
+	transactionDetails, err := payload.NewTransactionDetails(1, SwapSide{Mint: utils.SOL_ADDRESS, Symbol: "SOL"}, SwapSide{Mint: "USDC"}, resolvers)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if transactionDetails.FromTokenSymbol != "SOL" || transactionDetails.ToTokenSymbol != "USDC" {
+		t.Errorf("Incorrect symbols %s and %s", transactionDetails.FromTokenSymbol, transactionDetails.ToTokenSymbol)
+	}
+}
+
+func TestSwapLegsAndNetEffect(t *testing.T) {
+	body, err := os.ReadFile("../test_data/solana_swap_example.json")
+	if err != nil {
+		log.Fatalf("unable to read file: %v", err)
+	}
+	var payloads []SolanaPayload
+	err = json.Unmarshal(body, &payloads)
+	if err != nil {
+		t.Fatalf("Unmarshalling failed: %v", err)
+	}
+	payload := payloads[0]
+
+	t.Run("Keeps every hop of the route in order", func(t *testing.T) {
+		legs := payload.SwapLegs()
+		if len(legs) != 3 {
+			t.Fatalf("Expected 3 legs, got %d", len(legs))
+		}
+		if legs[0].Program != "LIFINITY" || legs[0].InputAmount != "448.032565" || legs[0].OutputMint != utils.SOL_ADDRESS {
+			t.Errorf("Incorrect first leg: %+v", legs[0])
+		}
+		if legs[2].Source != "ORCA" || legs[2].OutputAmount != "317.438359" {
+			t.Errorf("Incorrect last leg: %+v", legs[2])
+		}
+	})
+	t.Run("Sums the balance changes of the fee payer", func(t *testing.T) {
+		netEffect := payload.FeePayerNetEffect()
+		if len(netEffect) != 3 {
+			t.Fatalf("Expected 3 balance changes, got %d", len(netEffect))
+		}
+		if netEffect[0].Mint != utils.SOL_ADDRESS || netEffect[0].AmountDecimal != "-0.000105" {
+			t.Errorf("Incorrect SOL change: %+v", netEffect[0])
+		}
+		if netEffect[1].AmountDecimal != "447.198651" || netEffect[2].AmountDecimal != "-448.032565" {
+			t.Errorf("Incorrect token changes: %+v", netEffect[1:])
+		}
+	})
+	t.Run("Takes the sides from the tokens rather than the fees", func(t *testing.T) {
+		from, to := payload.FeePayerBalanceSides()
+		if from.Mint != "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB" || to.Mint != "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v" {
+			t.Errorf("Incorrect sides %s and %s", from.Mint, to.Mint)
+		}
+	})
+}
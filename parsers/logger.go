@@ -0,0 +1,10 @@
+package parsers
+
+import (
+	"log/slog"
+	"os"
+)
+
+var logHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}).WithAttrs([]slog.Attr{slog.String("service", "parsers")})
+
+var logger = slog.New(logHandler)
@@ -0,0 +1,82 @@
+package parsers
+
+import (
+	"errors"
+	"solana/models"
+)
+
+// Parser turns a Helius webhook payload into an event such as a swap or a transfer
+type Parser interface {
+	Name() string
+	Parse(payload *models.SolanaPayload, ID int64) (models.Event, error)
+}
+
+var ErrNoParser = errors.New("no parser registered for transaction")
+
+type registryKey struct {
+	transactionType string
+	source          string
+}
+
+// Registry picks the parsers for a payload by its Helius transaction type and source
+type Registry struct {
+	parsers   map[registryKey][]Parser
+	fallbacks []Parser
+}
+
+func NewRegistry() *Registry {
+	return &Registry{parsers: make(map[registryKey][]Parser)}
+}
+
+// Register adds a parser for the transaction type and source. An empty source registers the parser for every
+// source of the type. Parsers registered for the same key are tried in registration order.
+func (r *Registry) Register(transactionType, source string, parser Parser) {
+	key := registryKey{transactionType: transactionType, source: source}
+	r.parsers[key] = append(r.parsers[key], parser)
+}
+
+// RegisterFallback adds a parser tried for every payload once the parsers registered for it have failed
+func (r *Registry) RegisterFallback(parser Parser) {
+	r.fallbacks = append(r.fallbacks, parser)
+}
+
+// ParsersFor returns the chain of parsers for a payload: the ones for its type and source, then the ones for
+// its type, then the fallbacks. A parser appears only once, at its most specific position.
+func (r *Registry) ParsersFor(transactionType, source string) []Parser {
+	chain := make([]Parser, 0)
+	seen := make(map[Parser]bool)
+	add := func(parsers []Parser) {
+		for _, parser := range parsers {
+			if !seen[parser] {
+				seen[parser] = true
+				chain = append(chain, parser)
+			}
+		}
+	}
+	if source != "" {
+		add(r.parsers[registryKey{transactionType: transactionType, source: source}])
+	}
+	add(r.parsers[registryKey{transactionType: transactionType}])
+	add(r.fallbacks)
+	return chain
+}
+
+// Parse runs the chain of parsers for the payload and returns the result of the first one that succeeds. When
+// all of them fail, the error of the most specific parser is returned.
+func (r *Registry) Parse(payload *models.SolanaPayload, ID int64) (models.Event, error) {
+	var firstErr error
+	for _, parser := range r.ParsersFor(payload.Type, payload.Source) {
+		event, err := parser.Parse(payload, ID)
+		if err == nil {
+			return event, nil
+		}
+		logger.Debug("Parser failed", "parser", parser.Name(), "signature", payload.Signature, "type", payload.Type, "source", payload.Source, "error", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		return nil, ErrNoParser
+	}
+	return nil, firstErr
+}
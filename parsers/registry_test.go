@@ -0,0 +1,87 @@
+package parsers
+
+import (
+	"errors"
+	"solana/models"
+	"testing"
+)
+
+type stubParser struct {
+	name string
+	err  error
+}
+
+func (sp *stubParser) Name() string {
+	return sp.name
+}
+
+func (sp *stubParser) Parse(payload *models.SolanaPayload, ID int64) (models.Event, error) {
+	if sp.err != nil {
+		return nil, sp.err
+	}
+	return models.TransactionDetails{ID: ID, Description: sp.name}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	exact := &stubParser{name: "exact"}
+	typeWide := &stubParser{name: "type"}
+	fallback := &stubParser{name: "fallback"}
+	registry := NewRegistry()
+	registry.Register(TypeSwap, SourceJupiter, exact)
+	registry.Register(TypeSwap, "", typeWide)
+	registry.Register(TypeSwap, "", exact)
+	registry.RegisterFallback(fallback)
+
+	t.Run("orders the chain from the most specific parser to the fallbacks", func(t *testing.T) {
+		chain := registry.ParsersFor(TypeSwap, SourceJupiter)
+		expected := []string{"exact", "type", "fallback"}
+		if len(chain) != len(expected) {
+			t.Fatalf("Incorrect chain length %d should be %d", len(chain), len(expected))
+		}
+		for i, parser := range chain {
+			if parser.Name() != expected[i] {
+				t.Errorf("Incorrect parser at %d: got %s want %s", i, parser.Name(), expected[i])
+			}
+		}
+	})
+
+	t.Run("uses the fallbacks for unknown types", func(t *testing.T) {
+		chain := registry.ParsersFor("UNKNOWN", SourceJupiter)
+		if len(chain) != 1 || chain[0].Name() != "fallback" {
+			t.Errorf("Expected only the fallback parser, got %d parsers", len(chain))
+		}
+	})
+
+	t.Run("falls through to the next parser on failure", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register(TypeSwap, SourceOrca, &stubParser{name: "failing", err: models.ErrToTokenNotFound})
+		registry.RegisterFallback(fallback)
+
+		event, err := registry.Parse(&models.SolanaPayload{Type: TypeSwap, Source: SourceOrca}, 7)
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err)
+		}
+		transactionDetails := event.(models.TransactionDetails)
+		if transactionDetails.Description != "fallback" || transactionDetails.ID != 7 {
+			t.Errorf("Expected the fallback result with ID 7, got %s with ID %d", transactionDetails.Description, transactionDetails.ID)
+		}
+	})
+
+	t.Run("returns the error of the most specific parser when all fail", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register(TypeSwap, SourceOrca, &stubParser{name: "specific", err: models.ErrSameToken})
+		registry.RegisterFallback(&stubParser{name: "fallback", err: models.ErrToTokenNotFound})
+
+		_, err := registry.Parse(&models.SolanaPayload{Type: TypeSwap, Source: SourceOrca}, 0)
+		if !errors.Is(err, models.ErrSameToken) {
+			t.Errorf("Expected the specific parser error, got %v", err)
+		}
+	})
+
+	t.Run("fails without any parser", func(t *testing.T) {
+		_, err := NewRegistry().Parse(&models.SolanaPayload{Type: TypeSwap}, 0)
+		if !errors.Is(err, ErrNoParser) {
+			t.Errorf("Expected ErrNoParser, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package parsers
+
+import (
+	"solana/models"
+	"strings"
+)
+
+// Helius transaction types and sources the default registry has dedicated parsers for
+const (
+	TypeSwap     = "SWAP"
+	TypeNFTSale  = "NFT_SALE"
+	TypeTransfer = "TRANSFER"
+
+	SourceJupiter = "JUPITER"
+	SourceRaydium = "RAYDIUM"
+	SourceOrca    = "ORCA"
+	SourcePumpFun = "PUMP_FUN"
+)
+
+// sideExtractor finds the swap sides of a payload, leaving a side empty when it cannot tell
+type sideExtractor func(payload *models.SolanaPayload) (from models.SwapSide, to models.SwapSide)
+
+// swapParser runs its extractors in order, each one filling the sides the previous ones left empty
+type swapParser struct {
+	name       string
+	extractors []sideExtractor
+	resolvers  models.Resolvers
+}
+
+func (sp *swapParser) Name() string {
+	return sp.name
+}
+
+func (sp *swapParser) Parse(payload *models.SolanaPayload, ID int64) (models.Event, error) {
+	var from, to models.SwapSide
+	for _, extract := range sp.extractors {
+		if from.Mint != "" && to.Mint != "" {
+			break
+		}
+		extractedFrom, extractedTo := extract(payload)
+		if from.Mint == "" {
+			from = extractedFrom
+		}
+		if to.Mint == "" {
+			to = extractedTo
+		}
+	}
+	transactionDetails, err := payload.NewTransactionDetails(ID, from, to, sp.resolvers)
+	if err != nil {
+		return nil, err
+	}
+	return transactionDetails, nil
+}
+
+// routeSides follows a routed swap from the first input of its first hop to the last output of its last hop
+func routeSides(payload *models.SolanaPayload) (from models.SwapSide, to models.SwapSide) {
+	innerSwaps := payload.Events["swap"].InnerSwaps
+	if len(innerSwaps) == 0 {
+		return from, to
+	}
+	if inputs := innerSwaps[0].TokenInputs; len(inputs) > 0 {
+		from = inputs[0].SwapSide()
+	}
+	if outputs := innerSwaps[len(innerSwaps)-1].TokenOutputs; len(outputs) > 0 {
+		to = outputs[len(outputs)-1].SwapSide()
+	}
+
+	// Hops often lack raw amounts, the net balance change of the fee payer has them for the route as a whole
+	for _, change := range payload.FeePayerNetEffect() {
+		if change.Mint == from.Mint && from.Amount == "" && strings.HasPrefix(change.Amount, "-") {
+			from.Amount, from.Decimals = strings.TrimPrefix(change.Amount, "-"), change.Decimals
+		}
+		if change.Mint == to.Mint && to.Amount == "" && !strings.HasPrefix(change.Amount, "-") {
+			to.Amount, to.Decimals = change.Amount, change.Decimals
+		}
+	}
+	return from, to
+}
+
+func feePayerBalanceSides(payload *models.SolanaPayload) (models.SwapSide, models.SwapSide) {
+	return payload.FeePayerBalanceSides()
+}
+
+func swapEventSides(payload *models.SolanaPayload) (models.SwapSide, models.SwapSide) {
+	return payload.SwapEventSides()
+}
+
+// NewBalanceChangeSwapParser parses swaps from the balance changes of the fee payer, completed by the swap event.
+// It is the generic heuristic that works for most AMMs.
+func NewBalanceChangeSwapParser(resolvers models.Resolvers) Parser {
+	return &swapParser{name: "balance-change-swap", extractors: []sideExtractor{feePayerBalanceSides, swapEventSides}, resolvers: resolvers}
+}
+
+// NewSwapEventParser parses swaps from the swap event, whose amounts exclude the fees and rent that end up in the
+// SOL balance change. Balance changes fill in the sides the event reports as native SOL.
+func NewSwapEventParser(resolvers models.Resolvers) Parser {
+	return &swapParser{name: "swap-event", extractors: []sideExtractor{swapEventSides, feePayerBalanceSides}, resolvers: resolvers}
+}
+
+// NewRouteSwapParser parses aggregator swaps from their route, so the sides are the tokens the fee payer
+// started and ended with rather than an intermediate hop. Balance changes fill in what the route lacks.
+func NewRouteSwapParser(resolvers models.Resolvers) Parser {
+	return &swapParser{name: "route-swap", extractors: []sideExtractor{routeSides, swapEventSides, feePayerBalanceSides}, resolvers: resolvers}
+}
+
+// NewBalanceChangeParser parses trades from the balance changes of the fee payer alone, for programs that do
+// not emit a swap event such as bonding curves and NFT marketplaces
+func NewBalanceChangeParser(resolvers models.Resolvers) Parser {
+	return &swapParser{name: "balance-change", extractors: []sideExtractor{feePayerBalanceSides}, resolvers: resolvers}
+}
+
+// NewDefaultRegistry returns a registry with the parsers for the transaction shapes we know about. The parsers
+// name monitored wallets and tokens with the given resolvers.
+func NewDefaultRegistry(resolvers models.Resolvers) *Registry {
+	registry := NewRegistry()
+	balanceChangeSwap := NewBalanceChangeSwapParser(resolvers)
+	balanceChange := NewBalanceChangeParser(resolvers)
+	swapEvent := NewSwapEventParser(resolvers)
+
+	registry.Register(TypeSwap, SourceJupiter, NewRouteSwapParser(resolvers))
+	registry.Register(TypeSwap, SourceRaydium, swapEvent)
+	registry.Register(TypeSwap, SourceOrca, swapEvent)
+	registry.Register(TypeSwap, SourcePumpFun, balanceChange)
+	registry.Register(TypeSwap, "", balanceChangeSwap)
+	registry.Register(TypeNFTSale, "", balanceChange)
+	registry.Register(TypeTransfer, "", NewTransferParser(resolvers))
+	registry.RegisterFallback(balanceChangeSwap)
+	return registry
+}
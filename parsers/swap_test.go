@@ -0,0 +1,61 @@
+package parsers
+
+import (
+	"encoding/json"
+	"os"
+	"solana/models"
+	"testing"
+)
+
+func TestRouteSwapParser(t *testing.T) {
+	body, err := os.ReadFile("../test_data/solana_swap_example.json")
+	if err != nil {
+		t.Fatalf("unable to read file: %v", err)
+	}
+	var payloads []models.SolanaPayload
+	err = json.Unmarshal(body, &payloads)
+	if err != nil {
+		t.Fatalf("Unmarshalling failed: %v", err)
+	}
+
+	event, err := NewDefaultRegistry(models.Resolvers{}).Parse(&payloads[0], 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	transactionDetails := event.(models.TransactionDetails)
+	if transactionDetails.AmountOut != "448032565" || transactionDetails.AmountOutDecimal != "448.032565" {
+		t.Errorf("Incorrect amount out %s (%s)", transactionDetails.AmountOut, transactionDetails.AmountOutDecimal)
+	}
+	if transactionDetails.AmountIn != "447198651" || transactionDetails.ToTokenDecimal != 6 {
+		t.Errorf("Incorrect amount in %s with %d decimals", transactionDetails.AmountIn, transactionDetails.ToTokenDecimal)
+	}
+	if len(transactionDetails.Legs) != 3 || len(transactionDetails.NetEffect) != 3 {
+		t.Errorf("Expected 3 legs and 3 balance changes, got %d and %d", len(transactionDetails.Legs), len(transactionDetails.NetEffect))
+	}
+}
+
+func TestSwapEventParser(t *testing.T) {
+	payload := &models.SolanaPayload{
+		Type:      TypeSwap,
+		Source:    SourceRaydium,
+		Signature: "raydium",
+		FeePayer:  "F",
+		Events: map[string]models.SwapEvent{"swap": {
+			TokenInputs:  []models.TokenIO{{Mint: "A", RawTokenAmount: models.RawTokenAmount{TokenAmount: "100", Decimals: 2}}},
+			TokenOutputs: []models.TokenIO{{Mint: "B", RawTokenAmount: models.RawTokenAmount{TokenAmount: "5", Decimals: 0}}},
+		}},
+	}
+
+	event, err := NewSwapEventParser(models.Resolvers{}).Parse(payload, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Like every parser it reports the amount given away as AmountOut and the amount received as AmountIn
+	transactionDetails := event.(models.TransactionDetails)
+	if transactionDetails.FromToken != "A" || transactionDetails.AmountOut != "100" || transactionDetails.AmountOutDecimal != "1" {
+		t.Errorf("Incorrect amount out %s (%s) of %s", transactionDetails.AmountOut, transactionDetails.AmountOutDecimal, transactionDetails.FromToken)
+	}
+	if transactionDetails.ToToken != "B" || transactionDetails.AmountIn != "5" {
+		t.Errorf("Incorrect amount in %s of %s", transactionDetails.AmountIn, transactionDetails.ToToken)
+	}
+}
@@ -0,0 +1,25 @@
+package parsers
+
+import "solana/models"
+
+// transferParser turns the native and token transfers of a payload into transfer details
+type transferParser struct {
+	resolvers models.Resolvers
+}
+
+// NewTransferParser parses plain SOL and SPL token transfers
+func NewTransferParser(resolvers models.Resolvers) Parser {
+	return &transferParser{resolvers: resolvers}
+}
+
+func (tp *transferParser) Name() string {
+	return "transfer"
+}
+
+func (tp *transferParser) Parse(payload *models.SolanaPayload, ID int64) (models.Event, error) {
+	transferDetails, err := payload.NewTransferDetails(ID, payload.Transfers(), tp.resolvers)
+	if err != nil {
+		return nil, err
+	}
+	return transferDetails, nil
+}
@@ -1,23 +1,111 @@
 package routers
 
 import (
+	"crypto/subtle"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"net/http"
 	"solana/utils"
+	"strings"
 )
 
-// AuthMiddleware validates the JWT token
+// socketTokenProtocol is the websocket subprotocol a browser offers along with its token, since it cannot set
+// headers on the handshake: new WebSocket(url, ["bearer", token])
+const socketTokenProtocol = "bearer"
+
+// AuthMiddleware validates the JWT token of the Authorization header
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
+		authenticate(c, headerToken(c))
+	}
+}
+
+// SocketAuthMiddleware validates the JWT token of a websocket handshake or an event stream, taken from the token
+// query parameter, the Sec-WebSocket-Protocol header or the Authorization header. Browsers can set neither header
+// on an EventSource, so streams pass the token as query parameter.
+func SocketAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			tokenString = protocolToken(c)
+		}
+		if tokenString == "" {
+			tokenString = headerToken(c)
+		}
+		authenticate(c, tokenString)
+	}
+}
 
-		claims, valid := utils.ValidateToken(tokenString)
-		if !valid {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+// RequireRole only lets through requests authenticated with the given role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			logger.Error("Forbidden request", "username", c.GetString("username"), "path", c.FullPath(), "role", role)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
 			return
 		}
+		c.Next()
+	}
+}
+
+func authenticate(c *gin.Context, tokenString string) {
+	claims, valid := utils.ValidateToken(tokenString)
+	if !valid {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	role := claims.Role
+	if role == "" {
+		role = utils.RoleUser
+	}
+	c.Set("username", claims.Username)
+	c.Set("role", role)
+
+	c.Next()
+}
+
+func headerToken(c *gin.Context) string {
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// protocolToken returns the protocol offered next to socketTokenProtocol
+func protocolToken(c *gin.Context) string {
+	protocols := websocket.Subprotocols(c.Request)
+	for i, protocol := range protocols {
+		if protocol == socketTokenProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
 
-		c.Set("username", claims.Username)
+// WebhookAuthMiddleware validates the auth header Helius echoes on every webhook delivery. Any of the given
+// secrets is accepted, so the header can be rotated by configuring the new and the previous one side by side.
+func WebhookAuthMiddleware(secrets ...string) gin.HandlerFunc {
+	accepted := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			accepted = append(accepted, []byte(secret))
+		}
+	}
+	if len(accepted) == 0 {
+		logger.Error("No webhook auth header configured, all webhook deliveries will be rejected")
+	}
+
+	return func(c *gin.Context) {
+		header := []byte(c.GetHeader("Authorization"))
+
+		// Compare against every secret so the response time does not reveal which one matched
+		valid := 0
+		for _, secret := range accepted {
+			valid |= subtle.ConstantTimeCompare(header, secret)
+		}
+		if valid != 1 {
+			logger.Error("Invalid webhook auth header", "remoteAddr", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook auth header"})
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,118 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"solana/utils"
+	"testing"
+)
+
+func TestWebhookAuthMiddleware(t *testing.T) {
+	newRouter := func(secrets ...string) *gin.Engine {
+		router := gin.New()
+		router.Use(WebhookAuthMiddleware(secrets...))
+		router.POST("/api/webhook", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+	deliver := func(router *gin.Engine, header string) int {
+		request, _ := http.NewRequest("POST", "/api/webhook", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	t.Run("accepts the current and the previous secret", func(t *testing.T) {
+		router := newRouter("current", "previous")
+		for _, header := range []string{"current", "previous"} {
+			if status := deliver(router, header); status != http.StatusOK {
+				t.Errorf("Handler returned wrong status code for %s: got %v want %v", header, status, http.StatusOK)
+			}
+		}
+	})
+
+	t.Run("rejects a wrong or missing header", func(t *testing.T) {
+		router := newRouter("current", "")
+		for _, header := range []string{"wrong", "curren", ""} {
+			if status := deliver(router, header); status != http.StatusUnauthorized {
+				t.Errorf("Handler returned wrong status code for %q: got %v want %v", header, status, http.StatusUnauthorized)
+			}
+		}
+	})
+
+	t.Run("rejects everything when no secret is configured", func(t *testing.T) {
+		router := newRouter("", "")
+		if status := deliver(router, ""); status != http.StatusUnauthorized {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	userToken, _ := utils.GenerateToken("user", utils.RoleUser)
+	adminToken, _ := utils.GenerateToken("admin", utils.RoleAdmin)
+
+	router := gin.New()
+	cache := router.Group("/transactionCache")
+	cache.Use(AuthMiddleware())
+	SetupCachingRoutes(cache)
+	socket := router.Group("/socket")
+	socket.Use(SocketAuthMiddleware())
+	socket.GET("/transactionSocket", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	serve := func(method, path string, header http.Header) int {
+		request, _ := http.NewRequest(method, path, nil)
+		for key, values := range header {
+			request.Header[key] = values
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	t.Run("rejects requests without a valid token", func(t *testing.T) {
+		for _, token := range []string{"", "invalid"} {
+			header := http.Header{"Authorization": {token}}
+			if status := serve("GET", "/transactionCache/id", header); status != http.StatusUnauthorized {
+				t.Errorf("Handler returned wrong status code for %q: got %v want %v", token, status, http.StatusUnauthorized)
+			}
+		}
+		if status := serve("GET", "/socket/transactionSocket", nil); status != http.StatusUnauthorized {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("accepts a token with or without the bearer scheme", func(t *testing.T) {
+		for _, header := range []string{userToken, "Bearer " + userToken} {
+			if status := serve("GET", "/transactionCache/id", http.Header{"Authorization": {header}}); status != http.StatusOK {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+			}
+		}
+	})
+
+	t.Run("accepts the socket token as query parameter or subprotocol", func(t *testing.T) {
+		if status := serve("GET", "/socket/transactionSocket?token="+userToken, nil); status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code for the query parameter: got %v want %v", status, http.StatusOK)
+		}
+		header := http.Header{"Sec-Websocket-Protocol": {"bearer, " + userToken}}
+		if status := serve("GET", "/socket/transactionSocket", header); status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code for the subprotocol: got %v want %v", status, http.StatusOK)
+		}
+	})
+
+	t.Run("restricts clearing the cache to admins", func(t *testing.T) {
+		if status := serve("POST", "/transactionCache/clear", http.Header{"Authorization": {userToken}}); status != http.StatusForbidden {
+			t.Errorf("Handler returned wrong status code for a user: got %v want %v", status, http.StatusForbidden)
+		}
+		if status := serve("POST", "/transactionCache/clear", http.Header{"Authorization": {adminToken}}); status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code for an admin: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package routers
+
+import (
+	"expvar"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"solana/models"
+	"solana/services"
+)
+
+// deadLetters stores the webhook transactions that could not be parsed, it is nil until InitDeadLetters is called
+var deadLetters *services.DeadLettersService
+
+// deadLetterMetrics counts the parse failures per reason since the server started
+var deadLetterMetrics = expvar.NewMap("deadLetters")
+
+// InitDeadLetters enables recording unparseable webhook transactions into the given collection
+func InitDeadLetters(db *mongo.Collection) {
+	service := services.NewDeadLettersService(db)
+	err := service.EnsureIndexes()
+	if err != nil {
+		logger.Error("Error ensuring dead letter indexes", "error", err)
+	}
+	deadLetters = service
+}
+
+func recordDeadLetter(payload *models.SolanaPayload, err error) {
+	deadLetterMetrics.Add(models.FailureReason(err), 1)
+	if deadLetters == nil {
+		return
+	}
+	_ = deadLetters.Record(payload, err)
+}
+
+func removeDeadLetter(signature string) {
+	if deadLetters == nil {
+		return
+	}
+	_ = deadLetters.Remove(signature)
+}
+
+type DeadLettersRouter struct {
+	deadLettersService *services.DeadLettersService
+}
+
+func NewDeadLettersRouter(db *mongo.Collection, router *gin.RouterGroup) *DeadLettersRouter {
+	dlr := &DeadLettersRouter{deadLettersService: services.NewDeadLettersService(db)}
+	dlr.DeadLettersRegister(router)
+	return dlr
+}
+
+func (dlr *DeadLettersRouter) DeadLettersRegister(router *gin.RouterGroup) {
+	router.GET("/deadletters", dlr.listDeadLetters)
+	router.GET("/deadletters/stats", dlr.getDeadLetterStats)
+	router.GET("/deadletters/:signature", dlr.getDeadLetter)
+}
+
+// listDeadLetters @Summary List unparseable webhook transactions
+// @Description List unparseable webhook transactions, most recently seen first
+// @Tags Dead Letters
+// @Param reason query string false "Failure reason"
+// @Param type query string false "Helius transaction type"
+// @Param source query string false "Helius transaction source"
+// @Param limit query int false "Maximum number of dead letters"
+// @Success 200 {array} models.DeadLetter
+// @Failure 400 {object} Error
+// @Failure 500 {object} Error
+// @Router /deadletters [get]
+func (dlr *DeadLettersRouter) listDeadLetters(c *gin.Context) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+
+	filter := services.DeadLetterFilter{Reason: c.Query("reason"), Type: c.Query("type"), Source: c.Query("source")}
+	deadLetters, err := dlr.deadLettersService.ListDeadLetters(filter, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetters)
+}
+
+// getDeadLetter @Summary Inspect an unparseable webhook transaction
+// @Description Inspect an unparseable webhook transaction by signature
+// @Tags Dead Letters
+// @Param signature path string true "Transaction signature"
+// @Success 200 {object} models.DeadLetter
+// @Failure 404 {object} Error
+// @Failure 500 {object} Error
+// @Router /deadletters/{signature} [get]
+func (dlr *DeadLettersRouter) getDeadLetter(c *gin.Context) {
+	deadLetter, err := dlr.deadLettersService.GetDeadLetter(c.Param("signature"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if deadLetter == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+// getDeadLetterStats @Summary Count unparseable webhook transactions
+// @Description Count stored dead letters per failure reason, type and source, and parse failures per reason since startup
+// @Tags Dead Letters
+// @Success 200 {object} string
+// @Failure 500 {object} Error
+// @Router /deadletters/stats [get]
+func (dlr *DeadLettersRouter) getDeadLetterStats(c *gin.Context) {
+	counts, err := dlr.deadLettersService.CountDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sinceStartup := make(map[string]int64)
+	deadLetterMetrics.Do(func(kv expvar.KeyValue) {
+		if count, ok := kv.Value.(*expvar.Int); ok {
+			sinceStartup[kv.Key] = count.Value()
+		}
+	})
+
+	c.JSON(http.StatusOK, gin.H{"stored": counts, "sinceStartup": sinceStartup})
+}
@@ -0,0 +1,200 @@
+package routers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"solana/models"
+	"strings"
+)
+
+// eventFilter selects the broadcast events a client receives. An event matches when it matches every criterion
+// that is set, and a criterion matches when the event has any of its values. The zero filter matches everything.
+type eventFilter struct {
+	Wallets    []string `json:"wallets,omitempty"`
+	Accounts   []string `json:"accounts,omitempty"`
+	Mints      []string `json:"mints,omitempty"`
+	EventTypes []string `json:"eventTypes,omitempty"`
+}
+
+// parseEventFilter reads the filter from the wallet, account, mint and eventType query parameters, which can be
+// repeated or hold comma separated values
+func parseEventFilter(c *gin.Context) (eventFilter, error) {
+	filter := eventFilter{
+		Wallets:    queryValues(c, "wallet"),
+		Accounts:   queryValues(c, "account"),
+		Mints:      queryValues(c, "mint"),
+		EventTypes: queryValues(c, "eventType"),
+	}
+	return filter, filter.validate()
+}
+
+func queryValues(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
+
+func (f eventFilter) validate() error {
+	for _, eventType := range f.EventTypes {
+		if eventType != models.EventTypeSwap && eventType != models.EventTypeTransfer {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+func (f eventFilter) matches(event models.Event) bool {
+	return matchesAny(f.Wallets, event.GetAccountNames()) &&
+		matchesAny(f.Accounts, event.GetAccounts()) &&
+		matchesAny(f.Mints, event.GetMints()) &&
+		matchesAny(f.EventTypes, []string{event.GetEventType()})
+}
+
+// matchesAny tells whether the values share an entry with the wanted ones, or nothing is wanted
+func matchesAny(wanted []string, values []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if contains(wanted, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (f eventFilter) isEmpty() bool {
+	return len(f.Wallets) == 0 && len(f.Accounts) == 0 && len(f.Mints) == 0 && len(f.EventTypes) == 0
+}
+
+// values returns the values of the criteria, in the order of the fields
+func (f eventFilter) values() [][]string {
+	return [][]string{f.Wallets, f.Accounts, f.Mints, f.EventTypes}
+}
+
+// merged returns a single filter matching the events of both filters, if there is one. That is the case when
+// they differ in at most one criterion, which both set.
+func (f eventFilter) merged(other eventFilter) (eventFilter, bool) {
+	values, otherValues := f.values(), other.values()
+	differing := false
+	for i := range values {
+		if sameValues(values[i], otherValues[i]) {
+			continue
+		}
+		if differing || len(values[i]) == 0 || len(otherValues[i]) == 0 {
+			return eventFilter{}, false
+		}
+		differing = true
+	}
+	return f.with(other), true
+}
+
+// with returns the filter extended by the values of the other one
+func (f eventFilter) with(other eventFilter) eventFilter {
+	return eventFilter{
+		Wallets:    union(f.Wallets, other.Wallets),
+		Accounts:   union(f.Accounts, other.Accounts),
+		Mints:      union(f.Mints, other.Mints),
+		EventTypes: union(f.EventTypes, other.EventTypes),
+	}
+}
+
+// without returns the filter without the values of the other one
+func (f eventFilter) without(other eventFilter) eventFilter {
+	return eventFilter{
+		Wallets:    difference(f.Wallets, other.Wallets),
+		Accounts:   difference(f.Accounts, other.Accounts),
+		Mints:      difference(f.Mints, other.Mints),
+		EventTypes: difference(f.EventTypes, other.EventTypes),
+	}
+}
+
+// eventSubscriptions are the filters a client subscribed to. An event matches when it matches any of them, so
+// every subscription adds to what the client receives. No subscriptions match everything.
+type eventSubscriptions []eventFilter
+
+func newEventSubscriptions(filter eventFilter) eventSubscriptions {
+	if filter.isEmpty() {
+		return nil
+	}
+	return eventSubscriptions{filter}
+}
+
+func (s eventSubscriptions) matches(event models.Event) bool {
+	for _, filter := range s {
+		if filter.matches(event) {
+			return true
+		}
+	}
+	return len(s) == 0
+}
+
+// with returns the subscriptions with the filter added, merged into a subscription when that matches the same
+func (s eventSubscriptions) with(filter eventFilter) eventSubscriptions {
+	result := append(eventSubscriptions(nil), s...)
+	for i, subscription := range result {
+		if merged, ok := subscription.merged(filter); ok {
+			result[i] = merged
+			return result
+		}
+	}
+	return append(result, filter)
+}
+
+// without returns the subscriptions without the values of the filter. A subscription left without any value of
+// a criterion it set is dropped instead of matching more than before.
+func (s eventSubscriptions) without(filter eventFilter) eventSubscriptions {
+	var result eventSubscriptions
+	for _, subscription := range s {
+		remaining := subscription.without(filter)
+		kept := true
+		for i, values := range remaining.values() {
+			if len(values) == 0 && len(subscription.values()[i]) > 0 {
+				kept = false
+			}
+		}
+		if kept {
+			result = append(result, remaining)
+		}
+	}
+	return result
+}
+
+func union(values []string, added []string) []string {
+	result := append([]string(nil), values...)
+	for _, value := range added {
+		if !contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func difference(values []string, removed []string) []string {
+	var result []string
+	for _, value := range values {
+		if !contains(removed, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func sameValues(values []string, other []string) bool {
+	return len(difference(values, other)) == 0 && len(difference(other, values)) == 0
+}
@@ -11,6 +11,7 @@ import (
 type Credentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Role     string `json:"role,omitempty" bson:"role,omitempty"`
 }
 
 // Login handles user login and JWT token generation
@@ -42,7 +43,11 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := utils.GenerateToken(creds.Username)
+	role := parsedCreds.Role
+	if role == "" {
+		role = utils.RoleUser
+	}
+	token, err := utils.GenerateToken(creds.Username, role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
 		return
@@ -58,6 +63,10 @@ func Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	if creds.Role != "" && creds.Role != utils.RoleUser && creds.Role != utils.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
 	hashedPassword, err := utils.HashPassword(creds.Password)
 	if err != nil {
 		logger.Error("Error hashing password", "error", err)
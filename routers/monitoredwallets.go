@@ -7,6 +7,7 @@ import (
 	"solana/clients"
 	"solana/models"
 	"solana/services"
+	"strconv"
 )
 
 func (mwr *MonitoredWalletsRouter) MonitoredWalletRegister(router *gin.RouterGroup) {
@@ -15,15 +16,19 @@ func (mwr *MonitoredWalletsRouter) MonitoredWalletRegister(router *gin.RouterGro
 	router.DELETE("/monitored_wallets/:name", mwr.deleteMonitoredWallet)
 	router.PUT("/monitored_wallets/:name", mwr.updateMonitoredWallet)
 	router.GET("/monitored_wallets", mwr.getAllMonitoredWallets)
+	router.GET("/monitored_wallets/:name/pnl", mwr.getMonitoredWalletPnL)
 }
 
 type MonitoredWalletsRouter struct {
 	monitoredWalletsService *services.MonitoredWalletsService
+	pnlService              *services.PnLService
 }
 
-func NewMonitoredWalletsRouter(db *mongo.Collection, router *gin.RouterGroup, heliusApiKey, heliusWebhookID string) *MonitoredWalletsRouter {
-	hc := clients.NewHeliusClient(heliusApiKey, heliusWebhookID)
-	mwr := &MonitoredWalletsRouter{monitoredWalletsService: services.NewMonitoredWalletsService(db, *hc)}
+func NewMonitoredWalletsRouter(db *mongo.Collection, router *gin.RouterGroup, hc clients.HeliusAPI, names *services.WalletNameCache, pnl *services.PnLService) *MonitoredWalletsRouter {
+	mwr := &MonitoredWalletsRouter{monitoredWalletsService: services.NewMonitoredWalletsService(db, hc, names), pnlService: pnl}
+	if err := mwr.monitoredWalletsService.RefreshWalletNames(); err != nil {
+		logger.Error("Error loading wallet names", "error", err)
+	}
 	mwr.MonitoredWalletRegister(router)
 	return mwr
 }
@@ -202,3 +207,67 @@ func (mwr *MonitoredWalletsRouter) deleteMonitoredWallet(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Wallet deleted successfully"})
 }
+
+// getMonitoredWalletPnL @Summary Get the PnL of a monitored wallet
+// @Description Get the realized and unrealized PnL, open positions and win rate of the stored swaps of a monitored wallet
+// @Tags Monitored Wallets
+// @Param name path string true "Monitored wallet name"
+// @Param method query string false "Cost basis method, fifo (default) or average"
+// @Param from query int false "Unix timestamp of the first trade to realize"
+// @Param to query int false "Unix timestamp of the last trade to include"
+// @Success 200 {object} models.WalletPnL
+// @Failure 400 {object} Error
+// @Failure 404 {object} Error
+// @Failure 500 {object} Error
+// @Router /monitored_wallets/{name}/pnl [get]
+func (mwr *MonitoredWalletsRouter) getMonitoredWalletPnL(c *gin.Context) {
+	method := c.DefaultQuery("method", services.PnLMethodFIFO)
+	if method != services.PnLMethodFIFO && method != services.PnLMethodAverage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "method must be fifo or average"})
+		return
+	}
+	from, err := parseTimestamp(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a Unix timestamp"})
+		return
+	}
+	to, err := parseTimestamp(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a Unix timestamp"})
+		return
+	}
+
+	wallet, err := mwr.monitoredWalletsService.GetMonitoredWalletByName(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if wallet == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	pnl, err := mwr.pnlService.GetWalletPnL(wallet, method, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pnl)
+}
+
+// parseTimestamp reads an optional Unix timestamp query parameter, zero when it is missing
+func parseTimestamp(c *gin.Context, key string) (int64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if timestamp < 0 {
+		return 0, strconv.ErrRange
+	}
+	return timestamp, nil
+}
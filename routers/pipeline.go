@@ -0,0 +1,150 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"solana/models"
+	"sync"
+	"time"
+)
+
+const (
+	// QueueFullReject answers the whole delivery with 503 when the queue is full so Helius retries it later.
+	// Transactions of the batch that were already queued are acknowledged as duplicates on the retry.
+	QueueFullReject = "reject"
+	// QueueFullDrop acknowledges the delivery and drops the transactions that do not fit into the queue
+	QueueFullDrop = "drop"
+
+	// Outcomes of archived transactions that never reached a worker
+	webhookStatusRejected = "rejected"
+	webhookStatusDropped  = "dropped"
+
+	defaultWebhookQueueSize   = 1000
+	defaultWebhookWorkerCount = 4
+)
+
+var errWebhookQueueFull = errors.New("webhook queue is full")
+
+type webhookJob struct {
+	raw        json.RawMessage
+	payload    models.SolanaPayload
+	receivedAt time.Time
+}
+
+// webhookQueue decouples webhook deliveries from the processing of their transactions
+type webhookQueue struct {
+	jobs    chan webhookJob
+	policy  string
+	latency *latencyStats
+}
+
+var webhookPipeline = newWebhookQueue(defaultWebhookQueueSize, QueueFullReject)
+
+func init() {
+	webhookMetrics.Set("queueDepth", expvar.Func(func() interface{} {
+		return len(webhookPipeline.jobs)
+	}))
+	webhookMetrics.Set("queueCapacity", expvar.Func(func() interface{} {
+		return cap(webhookPipeline.jobs)
+	}))
+	webhookMetrics.Set("latency", expvar.Func(func() interface{} {
+		return webhookPipeline.latency.snapshot()
+	}))
+}
+
+func newWebhookQueue(size int, policy string) *webhookQueue {
+	if size <= 0 {
+		size = defaultWebhookQueueSize
+	}
+	if policy != QueueFullDrop {
+		policy = QueueFullReject
+	}
+	return &webhookQueue{jobs: make(chan webhookJob, size), policy: policy, latency: &latencyStats{}}
+}
+
+// StartWebhookPipeline replaces the webhook queue with one of the given size and policy and starts the
+// workers draining it. It must be called before the server starts accepting deliveries.
+func StartWebhookPipeline(workers, queueSize int, policy string) {
+	if workers <= 0 {
+		workers = defaultWebhookWorkerCount
+	}
+	webhookPipeline = newWebhookQueue(queueSize, policy)
+	logger.Info("Starting webhook pipeline", "workers", workers, "queueSize", cap(webhookPipeline.jobs), "policy", webhookPipeline.policy)
+	for i := 0; i < workers; i++ {
+		go webhookPipeline.work()
+	}
+}
+
+// enqueue queues the jobs without blocking. With the reject policy it stops at the first job that does not fit
+// and returns errWebhookQueueFull, with the drop policy the remaining jobs are dropped.
+// The delivery is then handed to the archive, the jobs that were not queued with the outcome rejected or dropped.
+func (q *webhookQueue) enqueue(jobs []webhookJob) (queued int, dropped int, err error) {
+	outcomes := make([]string, len(jobs))
+	defer func() {
+		archiveDelivery(jobs, outcomes, errWebhookQueueFull.Error())
+	}()
+	for i, job := range jobs {
+		select {
+		case q.jobs <- job:
+			queued++
+		default:
+			if q.policy == QueueFullReject {
+				webhookMetrics.Add(webhookStatusRejected, int64(len(jobs)-queued))
+				for j := i; j < len(jobs); j++ {
+					outcomes[j] = webhookStatusRejected
+				}
+				return queued, 0, errWebhookQueueFull
+			}
+			dropped++
+			webhookMetrics.Add(webhookStatusDropped, 1)
+			logger.Error("Dropping webhook transaction, queue is full", "signature", job.payload.Signature)
+			outcomes[i] = webhookStatusDropped
+		}
+	}
+	return queued, dropped, nil
+}
+
+func (q *webhookQueue) work() {
+	for job := range q.jobs {
+		result := processPayload(&job.payload)
+		webhookMetrics.Add(result.Status, 1)
+		archivePayload(job, result)
+		q.latency.observe(time.Since(job.receivedAt))
+	}
+}
+
+// latencyStats tracks the time from receiving a delivery until its transaction was processed
+type latencyStats struct {
+	sync.Mutex
+	count int64
+	total time.Duration
+	max   time.Duration
+	last  time.Duration
+}
+
+func (l *latencyStats) observe(latency time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.count++
+	l.total += latency
+	l.last = latency
+	if latency > l.max {
+		l.max = latency
+	}
+}
+
+func (l *latencyStats) snapshot() map[string]interface{} {
+	l.Lock()
+	defer l.Unlock()
+	var average time.Duration
+	if l.count > 0 {
+		average = l.total / time.Duration(l.count)
+	}
+	return map[string]interface{}{
+		"count":  l.count,
+		"avgMs":  average.Milliseconds(),
+		"maxMs":  l.max.Milliseconds(),
+		"lastMs": l.last.Milliseconds(),
+	}
+}
@@ -0,0 +1,215 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"solana/models"
+	"solana/services"
+	"time"
+)
+
+const (
+	webhookStatusUpdated = "updated"
+	webhookStatusFailed  = "failed"
+
+	defaultReplayLimit = 1000
+
+	// payloadArchiveQueueSize bounds the deliveries waiting to be archived
+	payloadArchiveQueueSize = 64
+)
+
+// payloadArchive stores every webhook transaction as delivered, it is nil until InitPayloadArchive is called
+var payloadArchive *services.PayloadArchiveService
+
+// deliveryArchive archives the transactions of the webhook deliveries off the request path, it is nil until
+// InitPayloadArchive is called
+var deliveryArchive *archiveQueue
+
+// archiveQueue hands the transactions of every delivery to a single goroutine, which archives each delivery with
+// one bulk write. A delivery that does not fit into the queue is not archived until a worker processes it.
+type archiveQueue struct {
+	archive    *services.PayloadArchiveService
+	deliveries chan []services.PayloadArchiveEntry
+}
+
+func newArchiveQueue(archive *services.PayloadArchiveService, size int) *archiveQueue {
+	return &archiveQueue{archive: archive, deliveries: make(chan []services.PayloadArchiveEntry, size)}
+}
+
+// add queues the delivery without blocking
+func (q *archiveQueue) add(entries []services.PayloadArchiveEntry) {
+	if q == nil || len(entries) == 0 {
+		return
+	}
+	select {
+	case q.deliveries <- entries:
+	default:
+		webhookMetrics.Add("archiveDropped", int64(len(entries)))
+		logger.Error("Not archiving webhook delivery, archive queue is full", "transactions", len(entries))
+	}
+}
+
+func (q *archiveQueue) work() {
+	for entries := range q.deliveries {
+		_ = q.archive.ArchiveMany(entries)
+	}
+}
+
+type replayRequest struct {
+	From       int64    `json:"from"`
+	To         int64    `json:"to"`
+	Signatures []string `json:"signatures"`
+	Limit      int64    `json:"limit"`
+}
+
+// InitPayloadArchive enables archiving the raw webhook transactions into the given collection
+func InitPayloadArchive(db *mongo.Collection) {
+	archive := services.NewPayloadArchiveService(db)
+	err := archive.EnsureIndexes()
+	if err != nil {
+		logger.Error("Error ensuring payload archive indexes", "error", err)
+	}
+	payloadArchive = archive
+	deliveryArchive = newArchiveQueue(archive, payloadArchiveQueueSize)
+	go deliveryArchive.work()
+}
+
+// archivePayload stores the raw transaction of the job with the outcome of processing it. Duplicates keep the
+// outcome of the delivery that was processed. The transaction is normally archived already with its delivery,
+// then only its outcome is updated.
+func archivePayload(job webhookJob, result webhookResult) {
+	if payloadArchive == nil {
+		return
+	}
+	outcome := result.Status
+	if outcome == webhookStatusDuplicate {
+		outcome = ""
+	}
+	_ = payloadArchive.Archive(job.raw, &job.payload, job.receivedAt, outcome, result.Error)
+}
+
+// archiveDelivery hands the transactions of a delivery to the archive queue, with the outcomes of those that were
+// not queued. The raw transactions are archived even when the queue drops or rejects them, so they can be
+// replayed.
+func archiveDelivery(jobs []webhookJob, outcomes []string, errorMessage string) {
+	if deliveryArchive == nil {
+		return
+	}
+	entries := make([]services.PayloadArchiveEntry, 0, len(jobs))
+	for i, job := range jobs {
+		entry := services.PayloadArchiveEntry{Raw: job.raw, Payload: &jobs[i].payload, ReceivedAt: job.receivedAt, Outcome: outcomes[i]}
+		if entry.Outcome != "" {
+			entry.Error = errorMessage
+		}
+		entries = append(entries, entry)
+	}
+	deliveryArchive.add(entries)
+}
+
+// ReplayHandler @Summary Replay archived webhook transactions
+// @Description Parses archived webhook transactions received in a time range or with the given signatures again
+// @Description and updates the stored transactions. Replayed transactions are not broadcast.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param request body replayRequest true "Unix time range in seconds and/or signatures to replay"
+// @Success 200 {object} string
+// @Failure 400 {object} Error
+// @Failure 500 {object} Error
+// @Router /webhook/replay [post]
+func ReplayHandler(c *gin.Context) {
+	if payloadArchive == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payload archive is not enabled"})
+		return
+	}
+
+	var request replayRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if request.From == 0 && request.To == 0 && len(request.Signatures) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A time range or signatures are required"})
+		return
+	}
+	if request.Limit <= 0 {
+		request.Limit = defaultReplayLimit
+	}
+
+	var from, to time.Time
+	if request.From != 0 {
+		from = time.Unix(request.From, 0)
+	}
+	if request.To != 0 {
+		to = time.Unix(request.To, 0)
+	}
+
+	archived, err := payloadArchive.FindPayloads(from, to, request.Signatures, request.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := make([]webhookResult, 0, len(archived))
+	counts := make(map[string]int)
+	for _, raw := range archived {
+		payload, err := raw.Decode()
+		var result webhookResult
+		if err != nil {
+			logger.Error("Error decoding archived payload", "error", err, "signature", raw.Signature)
+			result = webhookResult{Signature: raw.Signature, Status: webhookStatusFailed, Error: err.Error()}
+		} else {
+			result = replayPayload(&payload)
+		}
+		_ = payloadArchive.SetOutcome(raw.Signature, result.Status, result.Error)
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	logger.Info("Replayed archived payloads", "replayed", len(results), "counts", counts)
+	c.JSON(http.StatusOK, gin.H{"replayed": len(results), "counts": counts, "results": results})
+}
+
+// replayPayload parses an archived transaction with the current parsers. A transaction that is already stored
+// keeps its ID and is overwritten, a new one is cached like a live delivery but not broadcast.
+func replayPayload(payload *models.SolanaPayload) webhookResult {
+	event, err := transactionParsers.Parse(payload, 0)
+	if err != nil {
+		recordDeadLetter(payload, err)
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusSkipped, Error: err.Error()}
+	}
+	removeDeadLetter(payload.Signature)
+	event = valueEvent(event)
+
+	transactionCache.RLock()
+	store := transactionCache.store
+	transactionCache.RUnlock()
+
+	if store != nil {
+		existing, err := store.GetEventBySignature(event.GetEventType(), payload.Signature)
+		if err != nil {
+			return webhookResult{Signature: payload.Signature, Status: webhookStatusFailed, Error: err.Error()}
+		}
+		if existing != nil {
+			event = event.WithID(existing.GetID())
+			err = store.ReplaceEvent(event)
+			if err != nil {
+				return webhookResult{Signature: payload.Signature, Status: webhookStatusFailed, Error: err.Error()}
+			}
+			ID := event.GetID()
+			transactionCache.RLock()
+			transactionCache.entries.Replace(ID, event)
+			transactionCache.RUnlock()
+			return webhookResult{Signature: payload.Signature, Status: webhookStatusUpdated, ID: &ID}
+		}
+	}
+
+	seenSignatures.Add(payload.Signature)
+	event, err = cacheTransaction(event)
+	if err != nil {
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusFailed, Error: err.Error()}
+	}
+	ID := event.GetID()
+	return webhookResult{Signature: payload.Signature, Status: webhookStatusProcessed, ID: &ID}
+}
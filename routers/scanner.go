@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"github.com/gagliardetto/solana-go/rpc"
 	"github.com/gin-gonic/gin"
 	"solana/clients"
 	"solana/services"
@@ -8,12 +9,12 @@ import (
 )
 
 type ScannerRouter struct {
-	Helius *clients.HeliusClient
+	Helius clients.HeliusAPI
 	wtr    *services.WalletTriangulatorService
 }
 
-func NewScannerRouter(rpcURL string, helius *clients.HeliusClient) *ScannerRouter {
-	wtr := services.NewWalletTriangulatorService(rpcURL, helius)
+func NewScannerRouter(rpcClient *rpc.Client, helius clients.HeliusAPI) *ScannerRouter {
+	wtr := services.NewWalletTriangulatorService(rpcClient, helius)
 	return &ScannerRouter{Helius: helius, wtr: wtr}
 }
 
@@ -0,0 +1,203 @@
+package routers
+
+import (
+	"errors"
+	"expvar"
+	"github.com/gorilla/websocket"
+	"solana/models"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SlowConsumerDisconnect closes a connection whose send queue is full. The client reconnects with lastID
+	// and receives what it missed, so nothing is lost.
+	SlowConsumerDisconnect = "disconnect"
+	// SlowConsumerDropOldest makes room in a full send queue by dropping its oldest message
+	SlowConsumerDropOldest = "drop_oldest"
+
+	defaultSocketSendQueueSize = 256
+
+	socketWriteWait  = 10 * time.Second
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+)
+
+var errSocketClosed = errors.New("websocket closed")
+
+var websocketMetrics = expvar.NewMap("websocket")
+
+// socketHub fans the broadcast events out to the connected clients. Every client has its own send queue drained
+// by its write pump, so a slow client never holds up the others.
+type socketHub struct {
+	clients   sync.Map // *socketClient
+	count     atomic.Int64
+	queueSize int
+	policy    string
+}
+
+var hub = newSocketHub(defaultSocketSendQueueSize, SlowConsumerDisconnect)
+
+func init() {
+	websocketMetrics.Set("connected", expvar.Func(func() interface{} {
+		return hub.count.Load()
+	}))
+}
+
+func newSocketHub(queueSize int, policy string) *socketHub {
+	if queueSize <= 0 {
+		queueSize = defaultSocketSendQueueSize
+	}
+	if policy != SlowConsumerDropOldest {
+		policy = SlowConsumerDisconnect
+	}
+	return &socketHub{queueSize: queueSize, policy: policy}
+}
+
+// StartWebSocketManager replaces the hub with one of the given send queue size and slow consumer policy and starts
+// the goroutine that caches the events of the broker and sends them to the connected clients and to the event
+// streams. Caching them first lets a client that connects meanwhile replay them. It must be called before the
+// server starts accepting connections.
+func StartWebSocketManager(sendQueueSize int, policy string) {
+	hub = newSocketHub(sendQueueSize, policy)
+	logger.Info("Starting websocket manager", "sendQueueSize", hub.queueSize, "policy", hub.policy)
+	events := eventBroker.Events()
+	go func() {
+		for msg := range events {
+			rememberEvent(msg)
+			publishToStreams(msg)
+			hub.publish(msg)
+		}
+	}()
+}
+
+// connect registers the connection and starts its write pump. A client that reconnects is held in replay until
+// its missed events were sent.
+func (h *socketHub) connect(conn *websocket.Conn, filter eventFilter, replaying bool) *socketClient {
+	client := newSocketClient(conn, filter, h.queueSize)
+	client.replaying = replaying
+	client.onClose = func() {
+		h.clients.Delete(client)
+		h.count.Add(-1)
+	}
+	h.clients.Store(client, true)
+	h.count.Add(1)
+	go client.writePump()
+	return client
+}
+
+func (h *socketHub) publish(event models.Event) {
+	h.clients.Range(func(key, value interface{}) bool {
+		client := key.(*socketClient)
+		if !client.deliver(event, h.policy) {
+			logger.Error("Disconnecting slow websocket client", "remoteAddr", client.conn.RemoteAddr().String())
+			websocketMetrics.Add("slowDisconnects", 1)
+			client.close()
+		}
+		return true
+	})
+}
+
+func newSocketClient(conn *websocket.Conn, filter eventFilter, queueSize int) *socketClient {
+	return &socketClient{
+		conn:          conn,
+		subscriptions: newEventSubscriptions(filter),
+		send:          make(chan interface{}, queueSize),
+		done:          make(chan struct{}),
+	}
+}
+
+// write queues the message, waiting for room in the send queue. Replies and replayed events go through it,
+// so they are never dropped.
+func (sc *socketClient) write(message interface{}) error {
+	select {
+	case sc.send <- message:
+		return nil
+	case <-sc.done:
+		return errSocketClosed
+	}
+}
+
+// enqueue queues the message without waiting and reports whether it was queued. With the drop oldest policy a
+// full queue makes room by dropping its oldest message.
+func (sc *socketClient) enqueue(message interface{}, policy string) bool {
+	for {
+		select {
+		case sc.send <- message:
+			return true
+		default:
+		}
+		if policy != SlowConsumerDropOldest {
+			return false
+		}
+		select {
+		case <-sc.send:
+			websocketMetrics.Add("dropped", 1)
+		default:
+		}
+	}
+}
+
+// writePump is the only writer of the connection. It writes the queued messages and pings the client, which
+// has to answer before the pong wait runs out.
+func (sc *socketClient) writePump() {
+	ping := time.NewTicker(socketPingPeriod)
+	defer func() {
+		ping.Stop()
+		sc.close()
+		_ = sc.conn.Close()
+	}()
+	for {
+		select {
+		case <-sc.done:
+			_ = sc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(socketWriteWait))
+			return
+		case message := <-sc.send:
+			_ = sc.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := sc.conn.WriteJSON(message); err != nil {
+				logger.Error("Error writing to websocket", "error", err)
+				return
+			}
+		case <-ping.C:
+			if err := sc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				logger.Error("Error pinging websocket", "error", err)
+				return
+			}
+		}
+	}
+}
+
+// readPump reads the messages of the client and hands them to handle until the client closes the connection
+// or stops answering pings. Close frames are answered by the connection itself.
+func (sc *socketClient) readPump(handle func(data []byte) error) {
+	defer sc.close()
+	sc.conn.SetReadLimit(socketReadLimit)
+	_ = sc.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	sc.conn.SetPongHandler(func(string) error {
+		return sc.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	for {
+		_, data, err := sc.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Error("Error reading from websocket", "error", err)
+			}
+			return
+		}
+		if err = handle(data); err != nil {
+			return
+		}
+	}
+}
+
+// close unregisters the client and stops its write pump, which sends a close frame and closes the connection.
+// That in turn ends the read pump.
+func (sc *socketClient) close() {
+	sc.closeOnce.Do(func() {
+		close(sc.done)
+		if sc.onClose != nil {
+			sc.onClose()
+		}
+	})
+}
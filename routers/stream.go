@@ -0,0 +1,156 @@
+package routers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"solana/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	streamBufferSize        = 256
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// streams holds the channel of every open event stream. A stream that falls a whole buffer behind is closed
+// by the manager, its client reconnects and resumes from the last event it received.
+var streams sync.Map
+
+func subscribeStream() chan models.Event {
+	events := make(chan models.Event, streamBufferSize)
+	streams.Store(events, true)
+	return events
+}
+
+func unsubscribeStream(events chan models.Event) {
+	streams.Delete(events)
+}
+
+// publishToStreams hands the event to every stream without blocking
+func publishToStreams(event models.Event) {
+	streams.Range(func(key, value interface{}) bool {
+		events := key.(chan models.Event)
+		select {
+		case events <- event:
+		default:
+			if _, ok := streams.LoadAndDelete(events); ok {
+				logger.Error("Closing slow event stream", "id", event.GetID())
+				close(events)
+			}
+		}
+		return true
+	})
+}
+
+// StreamTransactionsHandler @Summary Transaction event stream
+// @Description Server-Sent Events stream of the broadcast events, filtered like the transaction socket.
+// @Description With a Last-Event-ID header or lastEventId query parameter the events after that ID are sent first.
+// @Description A client too far behind only gets the latest of them, announced by a truncated event naming the
+// @Description oldest one sent. It reads the older events from /api/transactions.
+// @Tags Stream
+// @Produce text/event-stream
+// @Param wallet query string false "Monitored wallet names, comma separated"
+// @Param account query string false "Public keys, comma separated"
+// @Param mint query string false "Mints, comma separated"
+// @Param eventType query string false "Event types, comma separated"
+// @Param lastEventId query int false "ID of the last event received"
+// @Success 200 {object} string
+// @Failure 400 {object} Error
+// @Router /stream/transactions [get]
+func StreamTransactionsHandler(c *gin.Context) {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	var lastID *int64
+	if lastEventID != "" {
+		ID, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+		lastID = &ID
+	}
+
+	// Subscribing before reading the backlog makes sure no event falls between the two
+	events := subscribeStream()
+	defer unsubscribeStream(events)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	replayed := make(map[int64]bool)
+	if lastID != nil {
+		backlog, truncated := GetAllTransactionsAfterSignature(*lastID)
+		if truncated && writeTruncated(c, backlog[0].GetID()) != nil {
+			return
+		}
+		for _, event := range backlog {
+			replayed[event.GetID()] = true
+			if filter.matches(event) && writeStreamEvent(c, event) != nil {
+				return
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			// Events broadcast while the backlog was read were already sent with it
+			if replayed[event.GetID()] {
+				delete(replayed, event.GetID())
+				continue
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			if writeStreamEvent(c, event) != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes the event with its cache ID, which the client sends back as Last-Event-ID when it reconnects
+func writeStreamEvent(c *gin.Context, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Error encoding stream event", "error", err, "id", event.GetID())
+		return nil
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\ndata: %s\n\n", event.GetID(), data)
+	return err
+}
+
+// writeTruncated tells the client that the events before oldestID were left out of its backlog
+func writeTruncated(c *gin.Context, oldestID int64) error {
+	data, _ := json.Marshal(gin.H{"oldestID": oldestID, "fallback": resumeFallback})
+	_, err := fmt.Fprintf(c.Writer, "event: truncated\ndata: %s\n\n", data)
+	return err
+}
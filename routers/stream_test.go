@@ -0,0 +1,110 @@
+package routers
+
+import (
+	"bufio"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"solana/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventFilter(t *testing.T) {
+	swap := models.TransactionDetails{Account: "A1", AccountName: "alice", FromToken: "SOL", ToToken: "BONK"}
+	transfer := models.TransferDetails{Account: "A2", Transfers: []models.Transfer{{From: "A2", To: "A3", ToName: "bob", Mint: "USDC"}}}
+
+	cases := []struct {
+		name     string
+		filter   eventFilter
+		swap     bool
+		transfer bool
+	}{
+		{"matches everything without criteria", eventFilter{}, true, true},
+		{"matches wallet names of both sides", eventFilter{Wallets: []string{"bob"}}, false, true},
+		{"matches any of the values", eventFilter{Mints: []string{"BONK", "USDC"}}, true, true},
+		{"requires every criterion", eventFilter{Accounts: []string{"A1"}, EventTypes: []string{models.EventTypeTransfer}}, false, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.filter.matches(swap) != c.swap || c.filter.matches(transfer) != c.transfer {
+				t.Errorf("Incorrect matches for %+v: swap %v transfer %v", c.filter, c.filter.matches(swap), c.filter.matches(transfer))
+			}
+		})
+	}
+
+	t.Run("parses repeated and comma separated query values", func(t *testing.T) {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request, _ = http.NewRequest("GET", "/stream/transactions?mint=A,B&mint=C&eventType=swap", nil)
+		filter, err := parseEventFilter(context)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(filter.Mints, ",") != "A,B,C" || len(filter.EventTypes) != 1 {
+			t.Errorf("Incorrect filter %+v", filter)
+		}
+	})
+
+	t.Run("rejects unknown event types", func(t *testing.T) {
+		context, _ := gin.CreateTestContext(httptest.NewRecorder())
+		context.Request, _ = http.NewRequest("GET", "/stream/transactions?eventType=mint", nil)
+		if _, err := parseEventFilter(context); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestStreamTransactionsHandler(t *testing.T) {
+	ClearCache()
+	cached, _ := cacheTransaction(models.TransactionDetails{Signature: "cached", AccountName: "alice"})
+
+	router := gin.New()
+	router.GET("/stream/transactions", StreamTransactionsHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/stream/transactions?wallet=alice", nil)
+	request.Header.Set("Last-Event-ID", strconv.FormatInt(cached.GetID()-1, 10))
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Incorrect content type %s", contentType)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "id: ") {
+				lines <- scanner.Text()
+			}
+		}
+		close(lines)
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(2 * time.Second):
+			return ""
+		}
+	}
+
+	if line := next(); line != "id: "+strconv.FormatInt(cached.GetID(), 10) {
+		t.Errorf("Incorrect replayed event %q", line)
+	}
+
+	// The stream subscribed before responding. The cached event is broadcast again to check it is not sent twice,
+	// and bob is filtered out.
+	publishToStreams(cached)
+	publishToStreams(models.TransactionDetails{ID: cached.GetID() + 1, AccountName: "bob"})
+	publishToStreams(models.TransactionDetails{ID: cached.GetID() + 2, AccountName: "alice"})
+	if line := next(); line != "id: "+strconv.FormatInt(cached.GetID()+2, 10) {
+		t.Errorf("Incorrect live event %q", line)
+	}
+}
@@ -0,0 +1,54 @@
+package routers
+
+import (
+	"github.com/gagliardetto/solana-go"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"solana/services"
+)
+
+type TokensRouter struct {
+	tokenMetadataService *services.TokenMetadataService
+}
+
+// NewTokensRouter serves the token metadata of the given service, shared with the transaction parsers so both
+// use the same cache
+func NewTokensRouter(tokens *services.TokenMetadataService, router *gin.RouterGroup) *TokensRouter {
+	tr := &TokensRouter{tokenMetadataService: tokens}
+	tr.TokensRegister(router)
+	return tr
+}
+
+func (tr *TokensRouter) TokensRegister(router *gin.RouterGroup) {
+	router.GET("/tokens/:mint", tr.getToken)
+}
+
+// getToken @Summary Get the metadata of a token
+// @Description Get the symbol, name, decimals and logo of a token by mint, fetching them from the chain when they are not stored yet and the logo from the off-chain metadata
+// @Tags Tokens
+// @Param mint path string true "Token mint"
+// @Success 200 {object} models.TokenMetadata
+// @Failure 400 {object} Error
+// @Failure 404 {object} Error
+// @Failure 500 {object} Error
+// @Router /tokens/{mint} [get]
+func (tr *TokensRouter) getToken(c *gin.Context) {
+	mint := c.Param("mint")
+	if _, err := solana.PublicKeyFromBase58(mint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mint"})
+		return
+	}
+
+	token, err := tr.tokenMetadataService.GetTokenWithLogo(mint)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if token == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
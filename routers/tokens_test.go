@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokensRouter(t *testing.T) {
+	t.Run("rejects a malformed mint", func(t *testing.T) {
+		router := gin.New()
+		NewTokensRouter(nil, router.Group("/api"))
+		request, _ := http.NewRequest("GET", "/api/tokens/not-a-mint", nil)
+		response := httptest.NewRecorder()
+
+		router.ServeHTTP(response, request)
+
+		if status := response.Code; status != http.StatusBadRequest {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"solana/models"
+	"solana/services"
+	"strconv"
+	"strings"
+)
+
+const defaultTransactionsLimit = 100
+
+type TransactionsRouter struct {
+	transactionsService *services.TransactionsService
+}
+
+func NewTransactionsRouter(db *mongo.Collection, router *gin.RouterGroup) *TransactionsRouter {
+	tr := &TransactionsRouter{transactionsService: services.NewTransactionsService(db)}
+	tr.TransactionsRegister(router)
+	return tr
+}
+
+func (tr *TransactionsRouter) TransactionsRegister(router *gin.RouterGroup) {
+	router.GET("/transactions", tr.listTransactions)
+	router.GET("/transactions/:signature", tr.getTransaction)
+	router.GET("/transactions/account/:account", tr.getTransactionsByAccount)
+	router.GET("/transactions/mint/:mint", tr.getTransactionsByMint)
+}
+
+// listTransactions @Summary List stored transactions a page at a time
+// @Description List stored transactions ordered by time, filtered and a page at a time. Pass the nextCursor of a page as cursor to get the next one.
+// @Tags Transactions
+// @Param account query string false "Account public key"
+// @Param accountName query string false "Monitored wallet name"
+// @Param mint query string false "Token mint swapped from or to"
+// @Param minAmount query string false "Minimum decimal amount of the mint, requires mint"
+// @Param status query string false "Transaction status"
+// @Param from query int false "Unix timestamp of the earliest transaction"
+// @Param to query int false "Unix timestamp of the latest transaction"
+// @Param sort query string false "asc or desc (default)"
+// @Param fields query string false "Comma separated fields to return"
+// @Param cursor query string false "Cursor of the page"
+// @Param limit query int false "Page size, at most 1000"
+// @Success 200 {object} string
+// @Failure 400 {object} Error
+// @Failure 500 {object} Error
+// @Router /transactions [get]
+func (tr *TransactionsRouter) listTransactions(c *gin.Context) {
+	query, err := parseTransactionQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transactions, next, err := tr.transactionsService.QueryTransactions(query)
+	if errors.Is(err, services.ErrInvalidTransactionQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.Encode()
+	}
+	if len(query.Fields) == 0 {
+		c.JSON(http.StatusOK, gin.H{"transactions": transactions, "nextCursor": nextCursor})
+		return
+	}
+	selected, err := selectFields(transactions, query.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transactions": selected, "nextCursor": nextCursor})
+}
+
+func parseTransactionQuery(c *gin.Context) (services.TransactionQuery, error) {
+	query := services.TransactionQuery{
+		Account:     c.Query("account"),
+		AccountName: c.Query("accountName"),
+		Mint:        c.Query("mint"),
+		Status:      c.Query("status"),
+		MinAmount:   c.Query("minAmount"),
+	}
+
+	var err error
+	query.Limit, err = parseLimit(c)
+	if err != nil || query.Limit > services.MaxTransactionPageSize {
+		return query, fmt.Errorf("limit must be a number between 1 and %d", services.MaxTransactionPageSize)
+	}
+	query.From, err = parseTimestamp(c, "from")
+	if err != nil {
+		return query, errors.New("from must be a Unix timestamp")
+	}
+	query.To, err = parseTimestamp(c, "to")
+	if err != nil {
+		return query, errors.New("to must be a Unix timestamp")
+	}
+
+	switch c.DefaultQuery("sort", "desc") {
+	case "asc":
+		query.Ascending = true
+	case "desc":
+	default:
+		return query, errors.New("sort must be asc or desc")
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		query.After, err = services.DecodeTransactionCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+	}
+
+	if fields := c.Query("fields"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if !services.IsTransactionField(field) {
+				return query, fmt.Errorf("unknown field %s", field)
+			}
+			query.Fields = append(query.Fields, field)
+		}
+	}
+	return query, nil
+}
+
+// selectFields returns the transactions as JSON objects holding only the given fields
+func selectFields(transactions []*models.TransactionDetails, fields []string) ([]map[string]json.RawMessage, error) {
+	selected := make([]map[string]json.RawMessage, 0, len(transactions))
+	for _, transaction := range transactions {
+		encoded, err := json.Marshal(transaction)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]json.RawMessage
+		err = json.Unmarshal(encoded, &all)
+		if err != nil {
+			return nil, err
+		}
+		subset := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := all[field]; ok {
+				subset[field] = value
+			}
+		}
+		selected = append(selected, subset)
+	}
+	return selected, nil
+}
+
+// getTransaction @Summary Get a stored transaction by signature
+// @Description Get a stored transaction by signature
+// @Tags Transactions
+// @Param signature path string true "Transaction signature"
+// @Success 200 {object} models.TransactionDetails
+// @Failure 404 {object} Error
+// @Failure 500 {object} Error
+// @Router /transactions/{signature} [get]
+func (tr *TransactionsRouter) getTransaction(c *gin.Context) {
+	signature := c.Param("signature")
+
+	transaction, err := tr.transactionsService.GetTransactionBySignature(signature)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if transaction == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+// getTransactionsByAccount @Summary Get the latest stored transactions of an account
+// @Description Get the latest stored transactions of an account, newest first
+// @Tags Transactions
+// @Param account path string true "Account public key"
+// @Param limit query int false "Maximum number of transactions"
+// @Success 200 {array} models.TransactionDetails
+// @Failure 400 {object} Error
+// @Failure 500 {object} Error
+// @Router /transactions/account/{account} [get]
+func (tr *TransactionsRouter) getTransactionsByAccount(c *gin.Context) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+
+	transactions, err := tr.transactionsService.GetTransactionsByAccount(c.Param("account"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// getTransactionsByMint @Summary Get the latest stored transactions of a token
+// @Description Get the latest stored transactions swapping from or to a mint, newest first
+// @Tags Transactions
+// @Param mint path string true "Token mint address"
+// @Param limit query int false "Maximum number of transactions"
+// @Success 200 {array} models.TransactionDetails
+// @Failure 400 {object} Error
+// @Failure 500 {object} Error
+// @Router /transactions/mint/{mint} [get]
+func (tr *TransactionsRouter) getTransactionsByMint(c *gin.Context) {
+	limit, err := parseLimit(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+
+	transactions, err := tr.transactionsService.GetTransactionsByMint(c.Param("mint"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+func parseLimit(c *gin.Context) (int64, error) {
+	limitString := c.Query("limit")
+	if limitString == "" {
+		return defaultTransactionsLimit, nil
+	}
+	limit, err := strconv.ParseInt(limitString, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, strconv.ErrRange
+	}
+	return limit, nil
+}
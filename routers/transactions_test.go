@@ -0,0 +1,57 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"solana/models"
+	"solana/services"
+	"testing"
+)
+
+func queryContext(rawQuery string) *gin.Context {
+	request, _ := http.NewRequest("GET", "/api/transactions?"+rawQuery, nil)
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = request
+	return context
+}
+
+func TestParseTransactionQuery(t *testing.T) {
+	t.Run("reads the filters, order, cursor and fields", func(t *testing.T) {
+		cursor := services.TransactionCursor{TimeStamp: 1700000000, ID: 7}
+		query, err := parseTransactionQuery(queryContext("account=alice&mint=BONK&minAmount=10&from=1&to=2&sort=asc&limit=5&fields=signature,amountIn&cursor=" + cursor.Encode()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if query.Account != "alice" || query.Mint != "BONK" || query.MinAmount != "10" || query.From != 1 || query.To != 2 {
+			t.Errorf("Incorrect filters: %+v", query)
+		}
+		if !query.Ascending || query.Limit != 5 || len(query.Fields) != 2 || *query.After != cursor {
+			t.Errorf("Incorrect paging: %+v", query)
+		}
+	})
+
+	for name, rawQuery := range map[string]string{
+		"rejects an unknown sort order": "sort=up",
+		"rejects an unknown field":      "fields=signature,password",
+		"rejects an oversized page":     "limit=5000",
+		"rejects a malformed cursor":    "cursor=abc",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseTransactionQuery(queryContext(rawQuery)); err == nil {
+				t.Errorf("Expected an error for %s", rawQuery)
+			}
+		})
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	transactions := []*models.TransactionDetails{{ID: 1, Signature: "sig", AmountIn: "5"}}
+	selected, err := selectFields(transactions, []string{"signature", "amountIn"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(selected[0]) != 2 || string(selected[0]["signature"]) != `"sig"` {
+		t.Errorf("Incorrect selection %v", selected[0])
+	}
+}
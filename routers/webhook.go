@@ -3,30 +3,150 @@ package routers
 import (
 	"encoding/json"
 	"errors"
+	"expvar"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 	"log/slog"
 	"net/http"
 	"os"
 	"solana/models"
+	"solana/parsers"
+	"solana/services"
+	"solana/utils"
 	"strconv"
 	"sync"
+	"time"
 )
 
 var logHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}).WithAttrs([]slog.Attr{slog.String("service", "routers")})
 
 var logger = slog.New(logHandler)
 
+const (
+	defaultTransactionCacheSize = 10000
+	defaultTransactionCacheTTL  = time.Hour
+)
+
+// maxResumeEvents bounds the events a client resuming after an ID is sent. Clients further behind read the older
+// events from resumeFallback.
+const (
+	maxResumeEvents = 1000
+	resumeFallback  = "/api/transactions"
+)
+
+// transactionCache is the hot tier in front of the event collections. It holds the latest events in ID order,
+// bounded in number and age. Every cached event is written through to the store, and reads the cache cannot
+// answer fall back to it.
+// ID is the next ID to assign, or with a shared sequence the one after the latest ID seen.
 var transactionCache = struct {
 	sync.RWMutex
-	m  map[int64]models.TransactionDetails
-	ID int64
-}{m: make(map[int64]models.TransactionDetails)}
+	entries  *utils.RingBuffer[models.Event]
+	ID       int64
+	store    *services.EventsService
+	sequence services.IDSequence
+}{entries: utils.NewRingBuffer[models.Event](defaultTransactionCacheSize, defaultTransactionCacheTTL)}
+
+func init() {
+	expvar.Publish("transactionCache", expvar.Func(func() interface{} {
+		transactionCache.RLock()
+		defer transactionCache.RUnlock()
+		return transactionCache.entries.Stats()
+	}))
+}
+
+const seenSignaturesCapacity = 10000
+
+// seenSignatures remembers recently ingested signatures so Helius retries are acknowledged without
+// being processed again. The unique signature index of the store covers anything older.
+var seenSignatures = utils.NewBoundedSet[string](seenSignaturesCapacity)
+
+var webhookMetrics = expvar.NewMap("webhook")
+
+// transactionParsers picks the parser for each webhook transaction by its Helius type and source
+var transactionParsers = parsers.NewDefaultRegistry(models.Resolvers{})
+
+// priceSource values parsed events in USD, nil leaves them unvalued
+var priceSource models.PriceSource
+
+// InitPriceSource makes new events carry USD values from the given price source
+func InitPriceSource(prices models.PriceSource) {
+	priceSource = prices
+}
+
+// valueEvent returns the event valued in USD when a price source is configured
+func valueEvent(event models.Event) models.Event {
+	if priceSource == nil {
+		return event
+	}
+	return event.WithUSDValues(priceSource)
+}
+
+// InitTransactionParsers makes the parsers name monitored wallets and tokens with the given resolvers
+func InitTransactionParsers(resolvers models.Resolvers) {
+	transactionParsers = parsers.NewDefaultRegistry(resolvers)
+}
+
+// InitTransactionCacheLimits replaces the cache with an empty one holding at most maxEntries events for at most ttl.
+// Values of zero or less keep the defaults.
+func InitTransactionCacheLimits(maxEntries int, ttl time.Duration) {
+	if maxEntries <= 0 {
+		maxEntries = defaultTransactionCacheSize
+	}
+	if ttl <= 0 {
+		ttl = defaultTransactionCacheTTL
+	}
+	transactionCache.Lock()
+	transactionCache.entries = utils.NewRingBuffer[models.Event](maxEntries, ttl)
+	transactionCache.Unlock()
+	logger.Info("Configured transaction cache", "maxEntries", maxEntries, "ttl", ttl.String())
+}
 
+// eventBroker delivers the processed events to the websocket and stream clients of every instance
+var eventBroker services.EventBroker = services.NewLocalBroker(broadcastBufferSize)
+
+// InitEventBroker makes the events reach the clients through the given broker. It must be called before the
+// websocket manager is started.
+func InitEventBroker(broker services.EventBroker) {
+	eventBroker = broker
+}
+
+// InitTransactionCache attaches the persistent store to the transaction cache and continues the ID sequence
+// from the last stored event, so IDs stay unique across restarts. Instances sharing the database pass a shared
+// sequence to draw their IDs from, nil keeps the sequence in the process.
+func InitTransactionCache(transactions *mongo.Collection, transfers *mongo.Collection, sequence services.IDSequence) {
+	store := services.NewEventsService(transactions, transfers)
+	err := store.EnsureIndexes()
+	if err != nil {
+		logger.Error("Error ensuring event indexes", "error", err)
+	}
+
+	latestID, err := store.GetLatestID()
+	if err != nil {
+		logger.Error("Error getting latest stored event ID", "error", err)
+	}
+
+	if sequence != nil && sequence.Continue(latestID) != nil {
+		logger.Error("Falling back to the in-process ID sequence")
+		sequence = nil
+	}
+
+	transactionCache.Lock()
+	transactionCache.store = store
+	transactionCache.sequence = sequence
+	if latestID >= transactionCache.ID {
+		transactionCache.ID = latestID + 1
+	}
+	transactionCache.Unlock()
+}
+
+// SetupCachingRoutes registers the cache routes, which expect the group to be authenticated. Clearing the cache
+// takes the admin role.
 func SetupCachingRoutes(router *gin.RouterGroup) {
-	router.POST("/clear", ClearCacheHandler)
+	router.POST("/clear", RequireRole(utils.RoleAdmin), ClearCacheHandler)
 	router.GET("/all", GetTransactionCacheHandler)
 	router.GET("", GetAllTransactionsAfterIDHandler)
 	router.GET("/id", GetLatestIDHandler)
+	router.GET("/stats", GetTransactionCacheStatsHandler)
 }
 
 // WebhookHandler @Summary Webhook handler
@@ -45,14 +165,14 @@ func WebhookHandler(context *gin.Context) {
 		return
 	}
 
-	var payload []models.SolanaPayload
+	var rawPayloads []json.RawMessage
 
 	if context.Request.Body == nil {
 		logger.Error("Empty request body")
 		_ = context.AbortWithError(http.StatusBadRequest, errors.New("empty request body"))
 		return
 	}
-	err := json.NewDecoder(context.Request.Body).Decode(&payload)
+	err := json.NewDecoder(context.Request.Body).Decode(&rawPayloads)
 
 	if err != nil {
 		logger.Error("Error decoding request body", "error", err)
@@ -60,22 +180,85 @@ func WebhookHandler(context *gin.Context) {
 		return
 	}
 
-	transactionCache.Lock()
-	idToUse := transactionCache.ID
-	transactionCache.Unlock()
-	transactionDetail, err := payload[0].GetTransactionDetails(idToUse)
+	// The raw transactions are kept next to the decoded ones so they can be archived as delivered
+	receivedAt := time.Now()
+	jobs := make([]webhookJob, 0, len(rawPayloads))
+	for _, raw := range rawPayloads {
+		job := webhookJob{raw: raw, receivedAt: receivedAt}
+		err = json.Unmarshal(raw, &job.payload)
+		if err != nil {
+			logger.Error("Error decoding request body", "error", err)
+			_ = context.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	queued, dropped, err := webhookPipeline.enqueue(jobs)
 	if err != nil {
-		context.JSON(http.StatusOK, gin.H{"status": "ok"})
+		logger.Error("Rejecting webhook delivery", "error", err, "received", len(jobs), "queued", queued)
+		context.Header("Retry-After", "5")
+		context.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
 		return
 	}
 
-	transactionCache.Lock()
-	transactionCache.m[transactionCache.ID] = transactionDetail
-	transactionCache.ID++
-	transactionCache.Unlock()
+	logger.Debug("Queued webhook batch", "received", len(jobs), "queued", queued, "dropped", dropped)
+	context.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"received": len(jobs),
+		"queued":   queued,
+		"dropped":  dropped,
+	})
+}
 
-	broadcast <- transactionDetail
-	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+const (
+	webhookStatusProcessed = "processed"
+	webhookStatusSkipped   = "skipped"
+	webhookStatusDuplicate = "duplicate"
+)
+
+// webhookResult reports what happened to a single transaction of a webhook delivery
+type webhookResult struct {
+	Signature string `json:"signature"`
+	Status    string `json:"status"`
+	ID        *int64 `json:"id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// processPayload decodes, caches and broadcasts a single transaction of a webhook delivery. Signatures that were
+// already ingested are acknowledged as duplicates without being cached or broadcast again.
+func processPayload(payload *models.SolanaPayload) webhookResult {
+	if !seenSignatures.Add(payload.Signature) {
+		logger.Info("Ignoring duplicate webhook transaction", "signature", payload.Signature)
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusDuplicate}
+	}
+
+	event, err := transactionParsers.Parse(payload, 0)
+	if err != nil {
+		// Let a later delivery be reported as skipped again rather than as a duplicate
+		seenSignatures.Remove(payload.Signature)
+		logger.Info("Skipping webhook transaction", "signature", payload.Signature, "type", payload.Type, "source", payload.Source, "error", err)
+		recordDeadLetter(payload, err)
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusSkipped, Error: err.Error()}
+	}
+
+	event, err = cacheTransaction(valueEvent(event))
+	if errors.Is(err, services.ErrDuplicateTransaction) {
+		logger.Info("Ignoring already stored webhook transaction", "signature", payload.Signature)
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusDuplicate}
+	}
+	if err != nil {
+		seenSignatures.Remove(payload.Signature)
+		logger.Error("Error assigning event ID", "error", err, "signature", payload.Signature)
+		return webhookResult{Signature: payload.Signature, Status: webhookStatusFailed, Error: err.Error()}
+	}
+
+	ID := event.GetID()
+	logger.Debug("Processed webhook transaction", "signature", payload.Signature, "id", ID, "eventType", event.GetEventType())
+	if err = eventBroker.Publish(event); err != nil {
+		logger.Error("Error publishing event", "error", err, "id", ID)
+	}
+	return webhookResult{Signature: payload.Signature, Status: webhookStatusProcessed, ID: &ID}
 }
 
 func ClearCacheHandler(context *gin.Context) {
@@ -95,41 +278,139 @@ func GetAllTransactionsAfterIDHandler(context *gin.Context) {
 		_ = context.AbortWithError(http.StatusBadRequest, err)
 		return
 	}
-	context.JSON(http.StatusOK, GetAllTransactionsAfterSignature(ID))
+	transactions, truncated := GetAllTransactionsAfterSignature(ID)
+	if truncated {
+		context.Header("X-Events-Truncated", "true")
+		context.Header("X-Events-Fallback", resumeFallback)
+	}
+	context.JSON(http.StatusOK, transactions)
 }
 
 func GetLatestIDHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, getLatestCacheID())
 }
 
-func GetAllTransactionsAfterSignature(ID int64) []models.TransactionDetails {
+// GetTransactionCacheStatsHandler reports the size, limits and eviction counts of the cache
+func GetTransactionCacheStatsHandler(c *gin.Context) {
 	transactionCache.RLock()
-	defer transactionCache.RUnlock()
-	transactions := make([]models.TransactionDetails, 0)
-	for id, transaction := range transactionCache.m {
-		if id > ID {
-			transactions = append(transactions, transaction)
+	entries := transactionCache.entries
+	transactionCache.RUnlock()
+	c.JSON(http.StatusOK, entries.Stats())
+}
+
+// cacheTransaction assigns the next cache ID to the event, writes it to the persistent store and then caches it.
+// Events the store already holds are not cached and services.ErrDuplicateTransaction is returned. They are looked
+// up before an ID is assigned, so redeliveries leave no gap in the IDs clients resume by. Any other failed write
+// is logged but does not keep the event out of the cache. An ID the shared sequence cannot hand out fails the
+// event.
+func cacheTransaction(event models.Event) (models.Event, error) {
+	transactionCache.RLock()
+	store := transactionCache.store
+	sequence := transactionCache.sequence
+	transactionCache.RUnlock()
+
+	if store != nil {
+		stored, err := store.GetEventBySignature(event.GetEventType(), event.GetSignature())
+		if err != nil {
+			logger.Error("Error looking up stored event", "error", err, "signature", event.GetSignature())
+		} else if stored != nil {
+			return event, services.ErrDuplicateTransaction
+		}
+	}
+
+	if sequence != nil {
+		ID, err := sequence.NextID()
+		if err != nil {
+			return event, err
 		}
+		event = event.WithID(ID)
+		advanceCacheID(ID)
+	} else {
+		transactionCache.Lock()
+		event = event.WithID(transactionCache.ID)
+		transactionCache.ID++
+		transactionCache.Unlock()
 	}
 
-	return transactions
+	if store != nil {
+		// Only a delivery stored by another instance since the lookup still costs an ID
+		err := store.SaveEvent(event)
+		if errors.Is(err, services.ErrDuplicateTransaction) {
+			return event, err
+		}
+		if err != nil {
+			logger.Error("Error persisting event", "error", err, "signature", event.GetSignature(), "id", event.GetID())
+		}
+	}
+
+	transactionCache.RLock()
+	transactionCache.entries.Put(event.GetID(), event)
+	transactionCache.RUnlock()
+	return event, nil
+}
+
+// rememberEvent caches an event published by any instance, so this one can replay it to its clients
+func rememberEvent(event models.Event) {
+	advanceCacheID(event.GetID())
+	transactionCache.RLock()
+	transactionCache.entries.Put(event.GetID(), event)
+	transactionCache.RUnlock()
+}
+
+// advanceCacheID moves the ID past the given one when another instance or the shared sequence got ahead
+func advanceCacheID(ID int64) {
+	transactionCache.Lock()
+	if ID >= transactionCache.ID {
+		transactionCache.ID = ID + 1
+	}
+	transactionCache.Unlock()
+}
+
+// GetAllTransactionsAfterSignature returns the events after the ID. A client further behind than maxResumeEvents
+// only gets the latest of them and truncated is set, it reads the older ones from resumeFallback.
+func GetAllTransactionsAfterSignature(ID int64) (transactions []models.Event, truncated bool) {
+	transactionCache.RLock()
+	transactions, oldestID, ok := transactionCache.entries.After(ID)
+	if !ok {
+		oldestID = transactionCache.ID
+	}
+	store := transactionCache.store
+	transactionCache.RUnlock()
+	missing := ID+1 < oldestID
+
+	// The requested ID was evicted, the cache was cleared or the server restarted since, so serve the range from storage
+	if missing && store != nil {
+		// One more than the window tells whether anything was left out
+		stored, err := store.GetEventsAfterID(ID, maxResumeEvents+1)
+		if err != nil {
+			logger.Error("Error reading events from storage", "error", err, "id", ID)
+		} else {
+			transactions = stored
+		}
+	}
+
+	if len(transactions) > maxResumeEvents {
+		transactions = transactions[len(transactions)-maxResumeEvents:]
+		truncated = true
+	}
+	return transactions, truncated
 }
 
+// ClearCache empties the cache. The ID sequence is only restarted when there is no persistent store,
+// otherwise new transactions would reuse the IDs of stored ones.
 func ClearCache() {
 	transactionCache.Lock()
-	transactionCache.m = make(map[int64]models.TransactionDetails)
-	transactionCache.ID = 0
+	transactionCache.entries.Clear()
+	if transactionCache.store == nil {
+		transactionCache.ID = 0
+	}
 	transactionCache.Unlock()
 }
 
-func GetTransactionCache() []models.TransactionDetails {
+func GetTransactionCache() []models.Event {
 	transactionCache.RLock()
 	defer transactionCache.RUnlock()
-	transactions := make([]models.TransactionDetails, 0)
-	for _, transaction := range transactionCache.m {
-		transactions = append(transactions, transaction)
-	}
-	return transactions
+	return transactionCache.entries.All()
 }
 
 func getLatestCacheID() int64 {
@@ -1,9 +1,22 @@
 package routers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"net/http"
 	"net/http/httptest"
+	"solana/models"
+	"solana/services"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -33,4 +46,331 @@ func TestWebhookHandler(t *testing.T) {
 			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 		}
 	})
+
+	t.Run("accepts an empty batch", func(t *testing.T) {
+		request, _ := http.NewRequest("POST", "/api/webhook", strings.NewReader("[]"))
+		response := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(response)
+		context.Request = request
+
+		WebhookHandler(context)
+
+		if status := response.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var body struct {
+			Received int `json:"received"`
+			Queued   int `json:"queued"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Unable to decode response body: %v", err)
+		}
+		if body.Received != 0 || body.Queued != 0 {
+			t.Errorf("Expected nothing to be queued, got %d received and %d queued", body.Received, body.Queued)
+		}
+	})
+
+	t.Run("rejects the delivery when the queue is full", func(t *testing.T) {
+		defer func(pipeline *webhookQueue) { webhookPipeline = pipeline }(webhookPipeline)
+		webhookPipeline = newWebhookQueue(1, QueueFullReject)
+
+		request, _ := http.NewRequest("POST", "/api/webhook", strings.NewReader(`[{"signature":"a"},{"signature":"b"}]`))
+		response := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(response)
+		context.Request = request
+
+		WebhookHandler(context)
+
+		if status := response.Code; status != http.StatusServiceUnavailable {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
+		}
+		if depth := len(webhookPipeline.jobs); depth != 1 {
+			t.Errorf("Incorrect queue depth %d should be %d", depth, 1)
+		}
+	})
+
+	t.Run("drops what does not fit when configured to", func(t *testing.T) {
+		defer func(pipeline *webhookQueue, archive *archiveQueue) {
+			webhookPipeline, deliveryArchive = pipeline, archive
+		}(webhookPipeline, deliveryArchive)
+		webhookPipeline = newWebhookQueue(1, QueueFullDrop)
+		archived := &outcomeCollection{outcomes: make(map[string]string)}
+		deliveryArchive = newArchiveQueue(services.NewPayloadArchiveService(archived), 1)
+
+		request, _ := http.NewRequest("POST", "/api/webhook", strings.NewReader(`[{"signature":"a"},{"signature":"b"}]`))
+		response := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(response)
+		context.Request = request
+
+		WebhookHandler(context)
+
+		if status := response.Code; status != http.StatusOK {
+			t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var body struct {
+			Queued  int `json:"queued"`
+			Dropped int `json:"dropped"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Unable to decode response body: %v", err)
+		}
+		if body.Queued != 1 || body.Dropped != 1 {
+			t.Errorf("Expected one queued and one dropped, got %d queued and %d dropped", body.Queued, body.Dropped)
+		}
+		// The delivery is archived after responding with a single write, both so the dropped one can be replayed
+		if len(archived.outcomes) != 0 {
+			t.Errorf("Expected nothing to be archived while handling the delivery, got %v", archived.outcomes)
+		}
+		close(deliveryArchive.deliveries)
+		deliveryArchive.work()
+		if archived.bulkWrites != 1 {
+			t.Errorf("Incorrect number of bulk writes %d should be %d", archived.bulkWrites, 1)
+		}
+		if outcome, ok := archived.outcomes["a"]; !ok || outcome != "" {
+			t.Errorf("Expected the queued transaction to be archived without an outcome, got %q", outcome)
+		}
+		if outcome := archived.outcomes["b"]; outcome != webhookStatusDropped {
+			t.Errorf("Incorrect outcome %q should be %q", outcome, webhookStatusDropped)
+		}
+	})
+}
+
+func webhookMetric(name string) int64 {
+	if value, ok := webhookMetrics.Get(name).(*expvar.Int); ok {
+		return value.Value()
+	}
+	return 0
+}
+
+func TestWebhookBatch(t *testing.T) {
+	defer func(pipeline *webhookQueue, broker services.EventBroker) {
+		webhookPipeline, eventBroker = pipeline, broker
+	}(webhookPipeline, eventBroker)
+	webhookPipeline = newWebhookQueue(10, QueueFullReject)
+	broker := services.NewLocalBroker(10)
+	eventBroker = broker
+	processedBefore, skippedBefore := webhookMetric(webhookStatusProcessed), webhookMetric(webhookStatusSkipped)
+	for _, signature := range []string{"batch-1", "batch-2", "batch-3"} {
+		seenSignatures.Remove(signature)
+	}
+
+	// The second transaction moves nothing, so no parser can make an event of it
+	batch := `[
+		{"signature": "batch-1", "type": "TRANSFER", "nativeTransfers": [{"fromUserAccount": "A", "toUserAccount": "B", "amount": 5}]},
+		{"signature": "batch-2", "type": "TRANSFER"},
+		{"signature": "batch-3", "type": "TRANSFER", "nativeTransfers": [{"fromUserAccount": "B", "toUserAccount": "C", "amount": 7}]}
+	]`
+	request, _ := http.NewRequest("POST", "/api/webhook", strings.NewReader(batch))
+	response := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(response)
+	context.Request = request
+
+	WebhookHandler(context)
+
+	if status := response.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	close(webhookPipeline.jobs)
+	webhookPipeline.work()
+
+	if processed := webhookMetric(webhookStatusProcessed) - processedBefore; processed != 2 {
+		t.Errorf("Incorrect number of processed transactions %d should be %d", processed, 2)
+	}
+	if skipped := webhookMetric(webhookStatusSkipped) - skippedBefore; skipped != 1 {
+		t.Errorf("Incorrect number of skipped transactions %d should be %d", skipped, 1)
+	}
+	for _, signature := range []string{"batch-1", "batch-3"} {
+		select {
+		case event := <-broker.Events():
+			if event.GetSignature() != signature {
+				t.Errorf("Incorrect event %s should be %s", event.GetSignature(), signature)
+			}
+		default:
+			t.Errorf("Expected an event for %s", signature)
+		}
+	}
+}
+
+func TestProcessPayload(t *testing.T) {
+	defer func(broker services.EventBroker) { eventBroker = broker }(eventBroker)
+	eventBroker = services.NewLocalBroker(10)
+	seenSignatures.Remove("unparseable")
+	seenSignatures.Remove("parseable")
+
+	t.Run("reports a transaction no parser understands", func(t *testing.T) {
+		result := processPayload(&models.SolanaPayload{Signature: "unparseable", Type: "TRANSFER"})
+		if result.Status != webhookStatusSkipped || result.Error == "" || result.ID != nil {
+			t.Errorf("Incorrect result %+v should be skipped with an error", result)
+		}
+	})
+
+	t.Run("reports a processed transaction with its ID", func(t *testing.T) {
+		payload := &models.SolanaPayload{Signature: "parseable", Type: "TRANSFER", NativeTransfers: []models.NativeTransfer{{FromUserAccount: "A", ToUserAccount: "B", Amount: 5}}}
+		result := processPayload(payload)
+		if result.Status != webhookStatusProcessed || result.ID == nil {
+			t.Errorf("Incorrect result %+v should be processed with an ID", result)
+		}
+		if result = processPayload(payload); result.Status != webhookStatusDuplicate {
+			t.Errorf("Incorrect status %s for a redelivery should be %s", result.Status, webhookStatusDuplicate)
+		}
+	})
+}
+
+// counterSequence is a shared sequence that another instance has already advanced
+type counterSequence struct {
+	next int64
+}
+
+func (cs *counterSequence) Continue(latestID int64) error {
+	return nil
+}
+
+func (cs *counterSequence) NextID() (int64, error) {
+	cs.next++
+	return cs.next, nil
+}
+
+func TestCacheTransactionWithSharedSequence(t *testing.T) {
+	ClearCache()
+	transactionCache.Lock()
+	transactionCache.sequence = &counterSequence{next: 1000}
+	transactionCache.Unlock()
+	defer func() {
+		transactionCache.Lock()
+		transactionCache.sequence = nil
+		transactionCache.Unlock()
+	}()
+
+	event, err := cacheTransaction(models.TransactionDetails{Signature: "shared"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event.GetID() != 1001 || getLatestCacheID() != 1001 {
+		t.Errorf("Incorrect ID %d with latest cache ID %d, both should be %d", event.GetID(), getLatestCacheID(), 1001)
+	}
+
+	// Events of other instances arrive through the broker
+	rememberEvent(models.TransferDetails{ID: 1005, Signature: "remote"})
+	events, _ := GetAllTransactionsAfterSignature(1001)
+	if len(events) != 1 || events[0].GetSignature() != "remote" || getLatestCacheID() != 1005 {
+		t.Errorf("Expected the remote event to be cached, got %v with latest cache ID %d", events, getLatestCacheID())
+	}
+}
+
+func TestGetAllTransactionsAfterSignatureTruncates(t *testing.T) {
+	ClearCache()
+	for i := 0; i < maxResumeEvents+5; i++ {
+		if _, err := cacheTransaction(models.TransactionDetails{Signature: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	events, truncated := GetAllTransactionsAfterSignature(-1)
+	if !truncated || len(events) != maxResumeEvents {
+		t.Fatalf("Expected %d events with truncated set, got %d and %t", maxResumeEvents, len(events), truncated)
+	}
+	if latest := events[len(events)-1].GetID(); latest != getLatestCacheID() {
+		t.Errorf("Incorrect last event %d should be the latest %d", latest, getLatestCacheID())
+	}
+
+	events, truncated = GetAllTransactionsAfterSignature(getLatestCacheID() - 3)
+	if truncated || len(events) != 3 {
+		t.Errorf("Expected 3 events without truncated, got %d and %t", len(events), truncated)
+	}
+}
+
+// memoryCollection stores documents by signature like a collection with a unique signature index
+type memoryCollection struct {
+	services.DBService
+	mu        sync.Mutex
+	documents map[string]interface{}
+}
+
+func newMemoryCollection() *memoryCollection {
+	return &memoryCollection{documents: make(map[string]interface{})}
+}
+
+func (mc *memoryCollection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, element := range filter.(bson.D) {
+		if element.Key != "signature" {
+			continue
+		}
+		if document, ok := mc.documents[fmt.Sprint(element.Value)]; ok {
+			return mongo.NewSingleResultFromDocument(document, nil, nil)
+		}
+	}
+	return mongo.NewSingleResultFromDocument(bson.D{}, mongo.ErrNoDocuments, nil)
+}
+
+func (mc *memoryCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	signature := document.(models.Event).GetSignature()
+	if _, ok := mc.documents[signature]; ok {
+		return nil, mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+	}
+	mc.documents[signature] = document
+	return &mongo.InsertOneResult{}, nil
+}
+
+func TestCacheTransactionDuplicates(t *testing.T) {
+	ClearCache()
+	transactionCache.Lock()
+	transactionCache.store = services.NewEventsService(newMemoryCollection(), newMemoryCollection())
+	transactionCache.Unlock()
+	defer func() {
+		transactionCache.Lock()
+		transactionCache.store = nil
+		transactionCache.Unlock()
+	}()
+
+	first, err := cacheTransaction(models.TransactionDetails{Signature: "first"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = cacheTransaction(models.TransactionDetails{Signature: "first"}); !errors.Is(err, services.ErrDuplicateTransaction) {
+		t.Errorf("Expected a duplicate error, got %v", err)
+	}
+	second, err := cacheTransaction(models.TransactionDetails{Signature: "second"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.GetID() != first.GetID()+1 {
+		t.Errorf("Incorrect ID %d after a redelivery should be %d", second.GetID(), first.GetID()+1)
+	}
+}
+
+// outcomeCollection records the outcome of every archived payload
+type outcomeCollection struct {
+	services.DBService
+	outcomes   map[string]string
+	bulkWrites int
+}
+
+func (oc *outcomeCollection) BulkWrite(ctx context.Context, writes []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	oc.bulkWrites++
+	for _, write := range writes {
+		update := write.(*mongo.UpdateOneModel)
+		_, _ = oc.UpdateOne(ctx, update.Filter, update.Update)
+	}
+	return &mongo.BulkWriteResult{}, nil
+}
+
+func (oc *outcomeCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	signature := fmt.Sprint(filter.(bson.D)[0].Value)
+	outcome := oc.outcomes[signature]
+	for _, operator := range update.(bson.D) {
+		if operator.Key != "$set" {
+			continue
+		}
+		for _, field := range operator.Value.(bson.D) {
+			if field.Key == "outcome" {
+				outcome = fmt.Sprint(field.Value)
+			}
+		}
+	}
+	oc.outcomes[signature] = outcome
+	return &mongo.UpdateResult{}, nil
 }
@@ -1,70 +1,239 @@
 package routers
 
 import (
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"net/http"
 	"solana/models"
+	"strconv"
 	"sync"
 )
 
-var (
-	connected sync.Map // Connected connected
-	broadcast = make(chan models.TransactionDetails)
+// broadcastBufferSize lets the webhook workers hand over transactions while the manager is still writing
+const broadcastBufferSize = 256
+
+// socketReadLimit bounds the size of the subscription messages a client sends
+const socketReadLimit = 4096
+
+const (
+	socketActionSubscribe   = "subscribe"
+	socketActionUnsubscribe = "unsubscribe"
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Accepting the token protocol completes the handshake of clients that authenticate with it
+	Subprotocols: []string{socketTokenProtocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
+// socketClient is a connection and the events it subscribed to. It starts out subscribed to the filter of its
+// query parameters. Once an unsubscribe leaves no subscription the client is paused and receives nothing until it
+// subscribes again, instead of falling back to every event.
+// While the events a reconnecting client missed are replayed, live events are held back in pending, bounded by
+// the size of the send queue.
+type socketClient struct {
+	conn          *websocket.Conn
+	send          chan interface{}
+	done          chan struct{}
+	closeOnce     sync.Once
+	onClose       func()
+	mu            sync.Mutex
+	subscriptions eventSubscriptions
+	paused        bool
+	replaying     bool
+	pending       []models.Event
+}
+
+// socketMessage is a subscription change sent by a client. The request ID is echoed in the acknowledgement.
+type socketMessage struct {
+	Action    string `json:"action"`
+	RequestID string `json:"requestId,omitempty"`
+	eventFilter
+}
+
+// socketAck acknowledges a subscription change with the resulting subscriptions, or reports why it failed
+type socketAck struct {
+	Type          string             `json:"type"`
+	RequestID     string             `json:"requestId,omitempty"`
+	Subscriptions eventSubscriptions `json:"subscriptions,omitempty"`
+	Paused        bool               `json:"paused,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+func (sc *socketClient) wants(event models.Event) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return !sc.paused && sc.subscriptions.matches(event)
+}
+
+// deliver queues a live event the client subscribed to, or holds it back while the client is replaying.
+// It reports false when the event did not fit into the send queue, or while replaying when as many events as
+// the send queue holds are already held back.
+func (sc *socketClient) deliver(event models.Event, policy string) bool {
+	sc.mu.Lock()
+	wanted := !sc.paused && sc.subscriptions.matches(event)
+	if sc.replaying {
+		defer sc.mu.Unlock()
+		if !wanted {
+			return true
+		}
+		if len(sc.pending) >= cap(sc.send) {
+			return false
+		}
+		sc.pending = append(sc.pending, event)
+		return true
+	}
+	sc.mu.Unlock()
+
+	if !wanted {
+		return true
+	}
+	return sc.enqueue(event, policy)
+}
+
+// replay queues the events after lastID and the replayed message, then the live events held back meanwhile,
+// skipping those that were already replayed, before letting live events through. The client has to be registered
+// with replaying set before the missed events are read, so every event is either read or held back. The replayed
+// message reports whether the missed events were truncated to the latest maxResumeEvents.
+// The lock is never held while queueing, so delivering live events to the client does not wait for it.
+func (sc *socketClient) replay(lastID int64) error {
+	replayed := make(map[int64]bool)
+	count := 0
+	backlog, truncated := GetAllTransactionsAfterSignature(lastID)
+	for _, event := range backlog {
+		replayed[event.GetID()] = true
+		if !sc.wants(event) {
+			continue
+		}
+		if err := sc.write(event); err != nil {
+			return err
+		}
+		count++
+	}
+
+	// Live events are still held back, so none of them gets ahead of the marker
+	marker := gin.H{"type": "replayed", "lastID": lastID, "count": count}
+	if truncated {
+		marker["truncated"], marker["fallback"] = true, resumeFallback
+	}
+	if err := sc.write(marker); err != nil {
+		return err
+	}
+
+	for {
+		sc.mu.Lock()
+		pending := sc.pending
+		sc.pending = nil
+		if len(pending) == 0 {
+			sc.replaying = false
+			sc.mu.Unlock()
+			return nil
+		}
+		sc.mu.Unlock()
+
+		for _, event := range pending {
+			if replayed[event.GetID()] || !sc.wants(event) {
+				continue
+			}
+			if err := sc.write(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// apply changes the subscription as the message asks and returns its acknowledgement. An unsubscribe without
+// values drops the whole subscription.
+func (sc *socketClient) apply(message socketMessage) socketAck {
+	ack := socketAck{RequestID: message.RequestID}
+	if err := message.eventFilter.validate(); err != nil {
+		ack.Type, ack.Error = "error", err.Error()
+		return ack
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	switch message.Action {
+	case socketActionSubscribe:
+		if message.eventFilter.isEmpty() {
+			ack.Type, ack.Error = "error", "subscribe needs wallets, accounts, mints or eventTypes"
+			return ack
+		}
+		if sc.paused {
+			sc.subscriptions = nil
+		}
+		sc.subscriptions = sc.subscriptions.with(message.eventFilter)
+		sc.paused = false
+		ack.Type = "subscribed"
+	case socketActionUnsubscribe:
+		if message.eventFilter.isEmpty() {
+			sc.subscriptions = nil
+		} else {
+			sc.subscriptions = sc.subscriptions.without(message.eventFilter)
+		}
+		sc.paused = len(sc.subscriptions) == 0
+		ack.Type = "unsubscribed"
+	default:
+		ack.Type, ack.Error = "error", "unknown action "+message.Action
+		return ack
+	}
+	ack.Subscriptions, ack.Paused = append(eventSubscriptions(nil), sc.subscriptions...), sc.paused
+	return ack
+}
+
+// TransactionSocketHandler streams the broadcast events matching the wallet, account, mint and eventType
+// query parameters. Clients change what they receive with subscribe and unsubscribe messages such as
+// {"action": "subscribe", "requestId": "1", "wallets": ["alice"], "mints": ["..."]}. Each subscription matches
+// the events matching all of its criteria, and the client receives the events matching any of its subscriptions.
+// A client reconnecting with the lastID parameter first receives the events it missed since, followed by a
+// {"type": "replayed"} message, and then the live events. A client too far behind only receives the latest
+// maxResumeEvents of them, the replayed message is then marked truncated and names the route serving the rest.
 func TransactionSocketHandler(c *gin.Context) {
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var lastID *int64
+	if lastIDstr := c.Query("lastID"); lastIDstr != "" {
+		ID, err := strconv.ParseInt(lastIDstr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last ID"})
+			return
+		}
+		lastID = &ID
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		logger.Error("Failed to set websocket upgrade", err)
+		logger.Error("Failed to set websocket upgrade", "error", err)
 		return
 	}
 
-	connected.Store(conn, true)
+	client := hub.connect(conn, filter, lastID != nil)
 
-	err = conn.WriteJSON(gin.H{"message": "Connected to transaction socket"})
+	err = client.write(gin.H{"message": "Connected to transaction socket"})
 	if err != nil {
-		logger.Error("Error writing to websocket", "error", err)
+		client.close()
 		return
 	}
-}
 
-// StartWebSocketManager starts a goroutine that sends messages to WebSocket connected
-func StartWebSocketManager() {
-	go func() {
-		for {
-			msg := <-broadcast
-
-			connected.Range(func(key, value interface{}) bool {
-				client, ok := key.(*websocket.Conn)
-				if !ok {
-					return true
-				}
-
-				err := client.WriteJSON(msg)
-				if err != nil {
-					logger.Error("Error writing to websocket", "error", err)
-					err = client.Close()
-					if err != nil {
-						logger.Error("Error closing websocket", "error", err)
-						return false
-					}
-					disconnectClient(client)
-				}
-				return true
-			})
-		}
-	}()
-}
+	if lastID != nil && client.replay(*lastID) != nil {
+		client.close()
+		return
+	}
 
-func disconnectClient(conn *websocket.Conn) {
-	connected.Delete(conn)
+	client.readPump(func(data []byte) error {
+		var message socketMessage
+		ack := socketAck{Type: "error", Error: "invalid message"}
+		if json.Unmarshal(data, &message) == nil {
+			ack = client.apply(message)
+		}
+		return client.write(ack)
+	})
 }
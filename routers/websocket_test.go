@@ -0,0 +1,272 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"solana/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSocketClientSubscriptions(t *testing.T) {
+	alice := models.TransactionDetails{AccountName: "alice", FromToken: "SOL", ToToken: "BONK"}
+	bob := models.TransactionDetails{AccountName: "bob", FromToken: "SOL", ToToken: "WIF"}
+
+	t.Run("subscribing narrows a connection that receives everything", func(t *testing.T) {
+		client := &socketClient{}
+		ack := client.apply(socketMessage{Action: "subscribe", RequestID: "1", eventFilter: eventFilter{Wallets: []string{"alice"}}})
+		if ack.Type != "subscribed" || ack.RequestID != "1" || len(ack.Subscriptions) != 1 || len(ack.Subscriptions[0].Wallets) != 1 {
+			t.Errorf("Incorrect acknowledgement %+v", ack)
+		}
+		if !client.wants(alice) || client.wants(bob) {
+			t.Errorf("Expected only alice to be delivered")
+		}
+		client.apply(socketMessage{Action: "subscribe", eventFilter: eventFilter{Wallets: []string{"bob", "alice"}}})
+		if !client.wants(bob) || len(client.subscriptions) != 1 || len(client.subscriptions[0].Wallets) != 2 {
+			t.Errorf("Expected bob to be added once, got %v", client.subscriptions)
+		}
+	})
+
+	t.Run("unsubscribing the last value pauses the connection", func(t *testing.T) {
+		client := &socketClient{subscriptions: eventSubscriptions{{Wallets: []string{"alice"}}}}
+		ack := client.apply(socketMessage{Action: "unsubscribe", eventFilter: eventFilter{Wallets: []string{"alice"}}})
+		if ack.Type != "unsubscribed" || !ack.Paused {
+			t.Errorf("Incorrect acknowledgement %+v", ack)
+		}
+		if client.wants(alice) || client.wants(bob) {
+			t.Errorf("Expected nothing to be delivered")
+		}
+		client.apply(socketMessage{Action: "subscribe", eventFilter: eventFilter{Mints: []string{"WIF"}}})
+		if client.wants(alice) || !client.wants(bob) {
+			t.Errorf("Expected only WIF swaps to be delivered after subscribing again")
+		}
+	})
+
+	t.Run("subscriptions in different criteria both deliver", func(t *testing.T) {
+		client := newSocketClient(nil, eventFilter{Wallets: []string{"alice"}}, 1)
+		ack := client.apply(socketMessage{Action: "subscribe", eventFilter: eventFilter{Mints: []string{"WIF"}}})
+		if len(ack.Subscriptions) != 2 {
+			t.Errorf("Incorrect subscriptions %+v should be alice and WIF", ack.Subscriptions)
+		}
+		if !client.wants(alice) || !client.wants(bob) {
+			t.Errorf("Expected alice's swaps and the WIF swaps to be delivered")
+		}
+
+		// A subscription to alice's BONK swaps adds nothing, and unsubscribing alice drops it along with the first
+		client.apply(socketMessage{Action: "subscribe", eventFilter: eventFilter{Wallets: []string{"alice"}, Mints: []string{"BONK"}}})
+		client.apply(socketMessage{Action: "unsubscribe", eventFilter: eventFilter{Wallets: []string{"alice"}}})
+		if client.wants(alice) || !client.wants(bob) || len(client.subscriptions) != 1 {
+			t.Errorf("Expected only the WIF subscription to be left, got %+v", client.subscriptions)
+		}
+	})
+
+	t.Run("rejects invalid changes", func(t *testing.T) {
+		client := &socketClient{}
+		for _, message := range []socketMessage{
+			{Action: "subscribe"},
+			{Action: "watch", eventFilter: eventFilter{Wallets: []string{"alice"}}},
+			{Action: "subscribe", eventFilter: eventFilter{EventTypes: []string{"mint"}}},
+		} {
+			if ack := client.apply(message); ack.Type != "error" || ack.Error == "" {
+				t.Errorf("Expected an error for %+v, got %+v", message, ack)
+			}
+		}
+		if !client.wants(alice) {
+			t.Errorf("Expected failed changes to leave the subscription as it was")
+		}
+	})
+
+	t.Run("acknowledges messages over the socket", func(t *testing.T) {
+		router := gin.New()
+		router.GET("/socket/transactionSocket", TransactionSocketHandler)
+		server := httptest.NewServer(router)
+		defer server.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/socket/transactionSocket?wallet=alice", nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		var welcome map[string]string
+		_ = conn.ReadJSON(&welcome)
+		for message, expected := range map[string]string{
+			`{"action": "unsubscribe", "requestId": "2", "wallets": ["alice"]}`: "unsubscribed",
+			`not json`: "error",
+		} {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var ack socketAck
+			if err := conn.ReadJSON(&ack); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ack.Type != expected {
+				t.Errorf("Incorrect acknowledgement %+v for %s", ack, message)
+			}
+		}
+	})
+}
+
+func TestSocketClientReplay(t *testing.T) {
+	ClearCache()
+	first, _ := cacheTransaction(models.TransactionDetails{Signature: "first", AccountName: "alice"})
+	second, _ := cacheTransaction(models.TransactionDetails{Signature: "second", AccountName: "bob"})
+	third, _ := cacheTransaction(models.TransactionDetails{Signature: "third", AccountName: "alice"})
+
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _ := upgrader.Upgrade(w, r, nil)
+		serverConns <- conn
+	}))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	// The third event was also broadcast while the client was replaying, the live one only arrived that way
+	live := models.TransactionDetails{ID: third.GetID() + 1, Signature: "live", AccountName: "alice"}
+	client := newSocketClient(<-serverConns, eventFilter{Wallets: []string{"alice"}}, 16)
+	client.replaying = true
+	client.pending = []models.Event{third, live}
+	go client.writePump()
+	defer client.close()
+	if err := client.replay(first.GetID() - 1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if client.replaying || client.pending != nil {
+		t.Errorf("Expected the client to be live after replaying")
+	}
+
+	// The held back and the later live events come after the replayed message
+	client.deliver(models.TransactionDetails{ID: live.GetID() + 1, Signature: "after", AccountName: "alice"}, SlowConsumerDisconnect)
+	var missed, received []string
+	target := &missed
+	for len(received) < 2 {
+		var message struct {
+			Type      string `json:"type"`
+			Signature string `json:"signature"`
+			Count     int    `json:"count"`
+			Truncated bool   `json:"truncated"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if message.Type == "replayed" {
+			if message.Count != 2 || message.Truncated {
+				t.Errorf("Incorrect replay count %d should be %d without truncation", message.Count, 2)
+			}
+			target = &received
+			continue
+		}
+		*target = append(*target, message.Signature)
+	}
+	if strings.Join(missed, ",") != "first,third" {
+		t.Errorf("Incorrect missed events %v, expected first,third without %s", missed, second.GetSignature())
+	}
+	if strings.Join(received, ",") != "live,after" {
+		t.Errorf("Incorrect live events %v should be live,after", received)
+	}
+}
+
+func TestSocketClientReplayDoesNotBlockDelivery(t *testing.T) {
+	ClearCache()
+	_, _ = cacheTransaction(models.TransactionDetails{Signature: "missed", AccountName: "alice"})
+
+	// Without a write pump the missed event fills the send queue of one and the replayed message has to wait
+	client := newSocketClient(nil, eventFilter{Wallets: []string{"alice"}}, 1)
+	client.replaying = true
+	go func() { _ = client.replay(-1) }()
+	defer client.close()
+	for len(client.send) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	delivered := make(chan bool)
+	go func() {
+		delivered <- client.deliver(models.TransactionDetails{ID: 100, AccountName: "alice"}, SlowConsumerDisconnect)
+	}()
+	select {
+	case ok := <-delivered:
+		if !ok {
+			t.Error("Expected the live event to be held back")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the delivery not to wait for the replay")
+	}
+}
+
+func TestSocketClientPendingLimit(t *testing.T) {
+	client := newSocketClient(nil, eventFilter{Wallets: []string{"alice"}}, 2)
+	client.replaying = true
+	for i := int64(0); i < 2; i++ {
+		if !client.deliver(models.TransactionDetails{ID: i, AccountName: "alice"}, SlowConsumerDropOldest) {
+			t.Fatalf("Expected event %d to be held back", i)
+		}
+	}
+	if !client.deliver(models.TransactionDetails{ID: 2, AccountName: "bob"}, SlowConsumerDropOldest) {
+		t.Error("Expected an event the client does not want to be skipped")
+	}
+	if client.deliver(models.TransactionDetails{ID: 3, AccountName: "alice"}, SlowConsumerDropOldest) {
+		t.Error("Expected the client to overflow once the held back events fill the send queue")
+	}
+	if len(client.pending) != 2 {
+		t.Errorf("Incorrect number of held back events %d should be %d", len(client.pending), 2)
+	}
+}
+
+func TestSocketHub(t *testing.T) {
+	t.Run("drops the oldest queued message when full", func(t *testing.T) {
+		client := newSocketClient(nil, eventFilter{}, 2)
+		for id := int64(1); id <= 3; id++ {
+			if !client.deliver(models.TransactionDetails{ID: id}, SlowConsumerDropOldest) {
+				t.Errorf("Expected event %d to be queued", id)
+			}
+		}
+		first := (<-client.send).(models.Event)
+		if first.GetID() != 2 || len(client.send) != 1 {
+			t.Errorf("Incorrect queue, first event %d with %d more", first.GetID(), len(client.send))
+		}
+	})
+
+	t.Run("disconnects a slow consumer", func(t *testing.T) {
+		serverConns := make(chan *websocket.Conn, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _ := upgrader.Upgrade(w, r, nil)
+			serverConns <- conn
+		}))
+		defer server.Close()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		testHub := newSocketHub(1, SlowConsumerDisconnect)
+		client := newSocketClient(<-serverConns, eventFilter{}, 1)
+		client.onClose = func() {
+			testHub.clients.Delete(client)
+			testHub.count.Add(-1)
+		}
+		testHub.clients.Store(client, true)
+		testHub.count.Add(1)
+
+		// Without a write pump nothing drains the queue, so the second event does not fit
+		testHub.publish(models.TransactionDetails{ID: 1})
+		testHub.publish(models.TransactionDetails{ID: 2})
+		select {
+		case <-client.done:
+		default:
+			t.Errorf("Expected the slow client to be closed")
+		}
+		if count := testHub.count.Load(); count != 0 {
+			t.Errorf("Incorrect connected count %d should be %d", count, 0)
+		}
+	})
+}
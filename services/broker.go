@@ -0,0 +1,130 @@
+package services
+
+import (
+	"encoding/json"
+	"solana/clients"
+	"solana/models"
+	"time"
+)
+
+const resubscribeDelay = time.Second
+
+// EventBroker carries the processed events to the websocket and stream clients of every instance. Events()
+// delivers each published event once per instance, including the one that published it.
+type EventBroker interface {
+	Publish(event models.Event) error
+	Events() <-chan models.Event
+}
+
+// LocalBroker is the broker of a single instance
+type LocalBroker struct {
+	events chan models.Event
+}
+
+func NewLocalBroker(bufferSize int) *LocalBroker {
+	return &LocalBroker{events: make(chan models.Event, bufferSize)}
+}
+
+// Publish hands the event over, waiting while the buffer is full
+func (lb *LocalBroker) Publish(event models.Event) error {
+	lb.events <- event
+	return nil
+}
+
+func (lb *LocalBroker) Events() <-chan models.Event {
+	return lb.events
+}
+
+// PubSub is a Redis compatible publish/subscribe server
+type PubSub interface {
+	Publish(channel string, message []byte) error
+	Subscribe(channel string) (Subscription, error)
+}
+
+type Subscription interface {
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// PubSubBroker shares the events of all instances over a channel of a publish/subscribe server. Events published
+// while an instance resubscribes after losing its subscription do not reach its clients live, they catch up by
+// resuming from the last event ID they received.
+type PubSubBroker struct {
+	pubsub  PubSub
+	channel string
+	events  chan models.Event
+}
+
+// NewPubSubBroker subscribes to the channel and keeps receiving its events in the background
+func NewPubSubBroker(pubsub PubSub, channel string, bufferSize int) (*PubSubBroker, error) {
+	subscription, err := pubsub.Subscribe(channel)
+	if err != nil {
+		return nil, err
+	}
+	broker := &PubSubBroker{pubsub: pubsub, channel: channel, events: make(chan models.Event, bufferSize)}
+	go broker.receive(subscription)
+	return broker, nil
+}
+
+// NewRedisBroker shares the events over a channel of a Redis compatible server
+func NewRedisBroker(client *clients.RedisClient, channel string, bufferSize int) (*PubSubBroker, error) {
+	return NewPubSubBroker(redisPubSub{client}, channel, bufferSize)
+}
+
+type redisPubSub struct {
+	*clients.RedisClient
+}
+
+func (rp redisPubSub) Subscribe(channel string) (Subscription, error) {
+	subscription, err := rp.RedisClient.Subscribe(channel)
+	if err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (pb *PubSubBroker) Publish(event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	err = pb.pubsub.Publish(pb.channel, data)
+	if err != nil {
+		logger.Error("Error publishing event", "error", err, "id", event.GetID())
+	}
+	return err
+}
+
+func (pb *PubSubBroker) Events() <-chan models.Event {
+	return pb.events
+}
+
+func (pb *PubSubBroker) receive(subscription Subscription) {
+	for {
+		data, err := subscription.Receive()
+		if err != nil {
+			logger.Error("Lost event subscription", "error", err, "channel", pb.channel)
+			_ = subscription.Close()
+			subscription = pb.resubscribe()
+			continue
+		}
+		event, err := models.DecodeEvent(data)
+		if err != nil {
+			logger.Error("Error decoding published event", "error", err)
+			continue
+		}
+		pb.events <- event
+	}
+}
+
+func (pb *PubSubBroker) resubscribe() Subscription {
+	for {
+		time.Sleep(resubscribeDelay)
+		subscription, err := pb.pubsub.Subscribe(pb.channel)
+		if err == nil {
+			logger.Info("Resubscribed to events", "channel", pb.channel)
+			return subscription
+		}
+		logger.Error("Error resubscribing to events", "error", err, "channel", pb.channel)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"solana/models"
+	"testing"
+	"time"
+)
+
+// memoryPubSub hands every published message to the subscriptions of its channel
+type memoryPubSub struct {
+	subscriptions map[string][]chan []byte
+}
+
+type memorySubscription chan []byte
+
+func (ms memorySubscription) Receive() ([]byte, error) {
+	message, ok := <-ms
+	if !ok {
+		return nil, errors.New("closed")
+	}
+	return message, nil
+}
+
+func (ms memorySubscription) Close() error {
+	return nil
+}
+
+func (mp *memoryPubSub) Publish(channel string, message []byte) error {
+	for _, subscription := range mp.subscriptions[channel] {
+		subscription <- message
+	}
+	return nil
+}
+
+func (mp *memoryPubSub) Subscribe(channel string) (Subscription, error) {
+	subscription := make(chan []byte, 10)
+	mp.subscriptions[channel] = append(mp.subscriptions[channel], subscription)
+	return memorySubscription(subscription), nil
+}
+
+func TestPubSubBroker(t *testing.T) {
+	pubsub := &memoryPubSub{subscriptions: make(map[string][]chan []byte)}
+	publisher, err := NewPubSubBroker(pubsub, "events", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	other, _ := NewPubSubBroker(pubsub, "events", 10)
+
+	_ = publisher.Publish(models.TransactionDetails{ID: 7, EventType: models.EventTypeSwap, Signature: "swap"})
+	_ = publisher.Publish(models.TransferDetails{ID: 8, EventType: models.EventTypeTransfer, Signature: "transfer"})
+
+	for name, broker := range map[string]*PubSubBroker{"publisher": publisher, "other": other} {
+		for _, expected := range []models.Event{models.TransactionDetails{ID: 7}, models.TransferDetails{ID: 8}} {
+			select {
+			case event := <-broker.Events():
+				if event.GetID() != expected.GetID() || event.GetEventType() != expected.GetEventType() {
+					t.Errorf("Incorrect event %d of type %s for the %s", event.GetID(), event.GetEventType(), name)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Expected the %s to receive event %d", name, expected.GetID())
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"solana/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeadLettersService stores the webhook transactions that could not be parsed
+type DeadLettersService struct {
+	db DBService
+}
+
+// DeadLetterFilter narrows down listed dead letters, empty fields match everything
+type DeadLetterFilter struct {
+	Reason string
+	Type   string
+	Source string
+}
+
+func NewDeadLettersService(db DBService) *DeadLettersService {
+	return &DeadLettersService{db: db}
+}
+
+func (dls *DeadLettersService) EnsureIndexes() error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "signature", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "reason", Value: 1}, {Key: "lastSeen", Value: -1}}},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "source", Value: 1}}},
+	}
+	_, err := dls.db.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		logger.Error("Error creating dead letter indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Record stores the payload with the reason it could not be parsed. Recording the same signature again
+// updates the reason and counts the attempt.
+func (dls *DeadLettersService) Record(payload *models.SolanaPayload, parseErr error) error {
+	now := time.Now()
+	update := bson.D{
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "signature", Value: payload.Signature},
+			{Key: "firstSeen", Value: now},
+		}},
+		{Key: "$set", Value: bson.D{
+			{Key: "type", Value: payload.Type},
+			{Key: "source", Value: payload.Source},
+			{Key: "reason", Value: models.FailureReason(parseErr)},
+			{Key: "error", Value: parseErr.Error()},
+			{Key: "feePayer", Value: payload.FeePayer},
+			{Key: "description", Value: payload.Description},
+			{Key: "timestamp", Value: payload.Timestamp},
+			{Key: "lastSeen", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := dls.db.UpdateOne(context.Background(), bson.D{{Key: "signature", Value: payload.Signature}}, update, opts)
+	if err != nil {
+		logger.Error("Error recording dead letter", "error", err, "signature", payload.Signature)
+		return err
+	}
+	return nil
+}
+
+// Remove deletes the dead letter of a signature that was parsed successfully after all
+func (dls *DeadLettersService) Remove(signature string) error {
+	_, err := dls.db.DeleteOne(context.Background(), bson.D{{Key: "signature", Value: signature}})
+	if err != nil {
+		logger.Error("Error removing dead letter", "error", err, "signature", signature)
+		return err
+	}
+	return nil
+}
+
+func (dls *DeadLettersService) GetDeadLetter(signature string) (*models.DeadLetter, error) {
+	var deadLetter models.DeadLetter
+
+	result := dls.db.FindOne(context.Background(), bson.D{{Key: "signature", Value: signature}})
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		logger.Error("Error finding dead letter", "error", result.Err(), "signature", signature)
+		return nil, result.Err()
+	}
+
+	err := result.Decode(&deadLetter)
+	if err != nil {
+		logger.Error("Error decoding dead letter", "error", err)
+		return nil, err
+	}
+
+	return &deadLetter, nil
+}
+
+// ListDeadLetters returns the dead letters matching the filter, most recently seen first
+func (dls *DeadLettersService) ListDeadLetters(filter DeadLetterFilter, limit int64) ([]*models.DeadLetter, error) {
+	query := bson.D{}
+	if filter.Reason != "" {
+		query = append(query, bson.E{Key: "reason", Value: filter.Reason})
+	}
+	if filter.Type != "" {
+		query = append(query, bson.E{Key: "type", Value: filter.Type})
+	}
+	if filter.Source != "" {
+		query = append(query, bson.E{Key: "source", Value: filter.Source})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "lastSeen", Value: -1}}).SetLimit(limit)
+	var deadLetters = make([]*models.DeadLetter, 0)
+
+	cursor, err := dls.db.Find(context.Background(), query, opts)
+	if err != nil {
+		logger.Error("Error fetching dead letters", "error", err)
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			logger.Error("Error closing cursor", "error", err)
+			return
+		}
+	}(cursor, context.Background())
+
+	for cursor.Next(context.Background()) {
+		var deadLetter models.DeadLetter
+		err := cursor.Decode(&deadLetter)
+		if err != nil {
+			logger.Error("Error decoding dead letter", "error", err)
+			return nil, err
+		}
+		deadLetters = append(deadLetters, &deadLetter)
+	}
+
+	if err := cursor.Err(); err != nil {
+		logger.Error("Cursor iteration error", "error", err)
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+// CountDeadLetters returns the number of dead letters per failure reason, type and source, largest first
+func (dls *DeadLettersService) CountDeadLetters() ([]*models.DeadLetterCount, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "reason", Value: "$reason"},
+				{Key: "type", Value: "$type"},
+				{Key: "source", Value: "$source"},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "reason", Value: "$_id.reason"},
+			{Key: "type", Value: "$_id.type"},
+			{Key: "source", Value: "$_id.source"},
+			{Key: "count", Value: 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+	}
+
+	cursor, err := dls.db.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		logger.Error("Error counting dead letters", "error", err)
+		return nil, err
+	}
+
+	var counts = make([]*models.DeadLetterCount, 0)
+	err = cursor.All(context.Background(), &counts)
+	if err != nil {
+		logger.Error("Error decoding dead letter counts", "error", err)
+		return nil, err
+	}
+	return counts, nil
+}
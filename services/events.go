@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"solana/models"
+	"sort"
+)
+
+// EventsService persists every kind of event in the collection of its type. Event IDs share one sequence
+// across the collections.
+type EventsService struct {
+	transactions *TransactionsService
+	transfers    *TransfersService
+}
+
+func NewEventsService(transactions DBService, transfers DBService) *EventsService {
+	return &EventsService{transactions: NewTransactionsService(transactions), transfers: NewTransfersService(transfers)}
+}
+
+func (es *EventsService) EnsureIndexes() error {
+	if err := es.transactions.EnsureIndexes(); err != nil {
+		return err
+	}
+	return es.transfers.EnsureIndexes()
+}
+
+// SaveEvent stores the event, returning ErrDuplicateTransaction if its signature is already stored
+func (es *EventsService) SaveEvent(event models.Event) error {
+	switch e := event.(type) {
+	case models.TransactionDetails:
+		return es.transactions.SaveTransaction(&e)
+	case models.TransferDetails:
+		return es.transfers.SaveTransfer(&e)
+	default:
+		return fmt.Errorf("unsupported event type %s", event.GetEventType())
+	}
+}
+
+// ReplaceEvent overwrites the stored event with the same signature
+func (es *EventsService) ReplaceEvent(event models.Event) error {
+	switch e := event.(type) {
+	case models.TransactionDetails:
+		return es.transactions.ReplaceTransaction(&e)
+	case models.TransferDetails:
+		return es.transfers.ReplaceTransfer(&e)
+	default:
+		return fmt.Errorf("unsupported event type %s", event.GetEventType())
+	}
+}
+
+// GetEventBySignature returns the stored event of the given type with the signature, or nil if there is none
+func (es *EventsService) GetEventBySignature(eventType, signature string) (models.Event, error) {
+	switch eventType {
+	case models.EventTypeSwap:
+		transaction, err := es.transactions.GetTransactionBySignature(signature)
+		if err != nil || transaction == nil {
+			return nil, err
+		}
+		return *transaction, nil
+	case models.EventTypeTransfer:
+		transfer, err := es.transfers.GetTransferBySignature(signature)
+		if err != nil || transfer == nil {
+			return nil, err
+		}
+		return *transfer, nil
+	default:
+		return nil, fmt.Errorf("unsupported event type %s", eventType)
+	}
+}
+
+// GetEventsAfterID returns the latest limit stored events of every type with an ID greater than the given one,
+// in ID order
+func (es *EventsService) GetEventsAfterID(ID int64, limit int64) ([]models.Event, error) {
+	transactions, err := es.transactions.GetTransactionsAfterID(ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := es.transfers.GetTransfersAfterID(ID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0, len(transactions)+len(transfers))
+	for _, transaction := range transactions {
+		events = append(events, *transaction)
+	}
+	for _, transfer := range transfers {
+		events = append(events, *transfer)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].GetID() < events[j].GetID()
+	})
+	if int64(len(events)) > limit {
+		events = events[int64(len(events))-limit:]
+	}
+	return events, nil
+}
+
+// GetLatestID returns the highest stored event ID, or -1 when nothing has been stored yet
+func (es *EventsService) GetLatestID() (int64, error) {
+	latestTransactionID, err := es.transactions.GetLatestID()
+	if err != nil {
+		return -1, err
+	}
+	latestTransferID, err := es.transfers.GetLatestID()
+	if err != nil {
+		return -1, err
+	}
+	if latestTransferID > latestTransactionID {
+		return latestTransferID, nil
+	}
+	return latestTransactionID, nil
+}
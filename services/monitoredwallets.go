@@ -13,19 +13,36 @@ import (
 )
 
 type MonitoredWalletsService struct {
-	db DBService
-	hc clients.HeliusClient
+	db    DBService
+	hc    clients.HeliusAPI
+	names *WalletNameCache
 }
 
-func NewMonitoredWalletsService(db DBService, hc clients.HeliusClient) *MonitoredWalletsService {
-	return &MonitoredWalletsService{db: db, hc: hc}
+// NewMonitoredWalletsService returns a service that keeps the given name cache in sync with the wallets it
+// adds, updates and deletes. The cache may be nil.
+func NewMonitoredWalletsService(db DBService, hc clients.HeliusAPI, names *WalletNameCache) *MonitoredWalletsService {
+	return &MonitoredWalletsService{db: db, hc: hc, names: names}
+}
+
+// RefreshWalletNames reloads the wallet name cache from the database. On failure the cache keeps the names it
+// had.
+func (mws *MonitoredWalletsService) RefreshWalletNames() error {
+	if mws.names == nil {
+		return nil
+	}
+	wallets, err := mws.GetAllMonitoredWallets()
+	if err != nil {
+		return err
+	}
+	mws.names.Load(wallets)
+	return nil
 }
 
 func (mws *MonitoredWalletsService) GetMonitoredWalletByName(name string) (*models.MonitoredWallet, error) {
 	var wallet models.MonitoredWallet
 
 	// Finding the wallet by name
-	result := mws.db.FindOne(context.Background(), bson.D{{"name", name}})
+	result := mws.db.FindOne(context.Background(), bson.D{{Key: "name", Value: name}})
 
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
@@ -110,6 +127,9 @@ func (mws *MonitoredWalletsService) AddMonitoredWallet(wallet *models.MonitoredW
 		return fmt.Errorf("error inserting wallet into database")
 	}
 
+	if err = mws.RefreshWalletNames(); err != nil {
+		logger.Error("Error refreshing wallet names after adding a wallet", "error", err, "name", wallet.Name)
+	}
 	return nil
 }
 
@@ -145,18 +165,22 @@ func (mws *MonitoredWalletsService) DeleteMonitoredWallet(name string) error {
 		return err
 	}
 
-	_, err = mws.db.DeleteOne(context.Background(), bson.D{{"name", name}})
+	_, err = mws.db.DeleteOne(context.Background(), bson.D{{Key: "name", Value: name}})
 	if err != nil {
 		logger.Error("Error deleting wallet", "error", err)
 		return err
 	}
+
+	if err = mws.RefreshWalletNames(); err != nil {
+		logger.Error("Error refreshing wallet names after deleting a wallet", "error", err, "name", name)
+	}
 	return nil
 }
 
 func (mws *MonitoredWalletsService) UpdateMonitoredWallet(name string, updatedWallet *models.MonitoredWallet) (*models.MonitoredWallet, error) {
 	var wallet models.MonitoredWallet
 
-	result := mws.db.FindOne(context.Background(), bson.D{{"name", name}})
+	result := mws.db.FindOne(context.Background(), bson.D{{Key: "name", Value: name}})
 
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
@@ -175,11 +199,14 @@ func (mws *MonitoredWalletsService) UpdateMonitoredWallet(name string, updatedWa
 	}
 	wallet.Name = updatedWallet.Name
 	wallet.PublicKey = updatedWallet.PublicKey
-	result = mws.db.FindOneAndReplace(context.Background(), bson.D{{"name", name}}, wallet)
+	result = mws.db.FindOneAndReplace(context.Background(), bson.D{{Key: "name", Value: name}}, wallet)
 	if result.Err() != nil {
 		logger.Error("Error updating wallet", "error", result.Err())
 		return nil, result.Err()
 	}
 
+	if err = mws.RefreshWalletNames(); err != nil {
+		logger.Error("Error refreshing wallet names after updating a wallet", "error", err, "name", name)
+	}
 	return &wallet, nil
 }
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"solana/models"
+	"solana/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PayloadArchiveService keeps every webhook transaction as delivered so it can be parsed again later
+type PayloadArchiveService struct {
+	db DBService
+}
+
+func NewPayloadArchiveService(db DBService) *PayloadArchiveService {
+	return &PayloadArchiveService{db: db}
+}
+
+func (pas *PayloadArchiveService) EnsureIndexes() error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "signature", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "receivedAt", Value: 1}}},
+	}
+	_, err := pas.db.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		logger.Error("Error creating payload archive indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// PayloadArchiveEntry is a webhook transaction to archive, with the outcome of parsing it if there is one yet
+type PayloadArchiveEntry struct {
+	Raw        []byte
+	Payload    *models.SolanaPayload
+	ReceivedAt time.Time
+	Outcome    string
+	Error      string
+}
+
+// Archive stores the raw transaction with the outcome of parsing it. A signature is only archived once, later
+// deliveries of it keep the first payload and receipt time. An empty outcome leaves the stored one untouched.
+func (pas *PayloadArchiveService) Archive(raw []byte, payload *models.SolanaPayload, receivedAt time.Time, outcome, errorMessage string) error {
+	update, err := archiveUpdate(PayloadArchiveEntry{Raw: raw, Payload: payload, ReceivedAt: receivedAt, Outcome: outcome, Error: errorMessage})
+	if err != nil {
+		return err
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = pas.db.UpdateOne(context.Background(), bson.D{{Key: "signature", Value: payload.Signature}}, update, opts)
+	if err != nil {
+		logger.Error("Error archiving payload", "error", err, "signature", payload.Signature)
+		return err
+	}
+	return nil
+}
+
+// ArchiveMany stores the transactions like Archive does, in a single unordered bulk write. A transaction that
+// fails to compress is left out, the others are archived.
+func (pas *PayloadArchiveService) ArchiveMany(entries []PayloadArchiveEntry) error {
+	writes := make([]mongo.WriteModel, 0, len(entries))
+	for _, entry := range entries {
+		update, err := archiveUpdate(entry)
+		if err != nil {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "signature", Value: entry.Payload.Signature}}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	_, err := pas.db.BulkWrite(context.Background(), writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		logger.Error("Error archiving payloads", "error", err, "count", len(writes))
+		return err
+	}
+	return nil
+}
+
+// archiveUpdate is the upsert archiving the entry
+func archiveUpdate(entry PayloadArchiveEntry) (bson.D, error) {
+	compressed, err := utils.Compress(entry.Raw)
+	if err != nil {
+		logger.Error("Error compressing payload", "error", err, "signature", entry.Payload.Signature)
+		return nil, err
+	}
+
+	onInsert := bson.D{
+		{Key: "signature", Value: entry.Payload.Signature},
+		{Key: "type", Value: entry.Payload.Type},
+		{Key: "timestamp", Value: entry.Payload.Timestamp},
+		{Key: "receivedAt", Value: entry.ReceivedAt},
+		{Key: "payload", Value: compressed},
+	}
+	update := bson.D{{Key: "$setOnInsert", Value: onInsert}}
+	if entry.Outcome != "" {
+		update = append(update, bson.E{Key: "$set", Value: outcomeFields(entry.Outcome, entry.Error)})
+	}
+	return update, nil
+}
+
+// SetOutcome records the outcome of parsing an archived transaction again
+func (pas *PayloadArchiveService) SetOutcome(signature, outcome, errorMessage string) error {
+	update := bson.D{{Key: "$set", Value: outcomeFields(outcome, errorMessage)}}
+	_, err := pas.db.UpdateOne(context.Background(), bson.D{{Key: "signature", Value: signature}}, update)
+	if err != nil {
+		logger.Error("Error updating payload outcome", "error", err, "signature", signature)
+		return err
+	}
+	return nil
+}
+
+// FindPayloads returns the archived transactions received within the time range, or with one of the given
+// signatures when any are given, oldest first. Zero times leave the range open on that side.
+func (pas *PayloadArchiveService) FindPayloads(from, to time.Time, signatures []string, limit int64) ([]*models.RawPayload, error) {
+	filter := bson.D{}
+	if len(signatures) > 0 {
+		filter = append(filter, bson.E{Key: "signature", Value: bson.D{{Key: "$in", Value: signatures}}})
+	}
+	receivedAt := bson.D{}
+	if !from.IsZero() {
+		receivedAt = append(receivedAt, bson.E{Key: "$gte", Value: from})
+	}
+	if !to.IsZero() {
+		receivedAt = append(receivedAt, bson.E{Key: "$lte", Value: to})
+	}
+	if len(receivedAt) > 0 {
+		filter = append(filter, bson.E{Key: "receivedAt", Value: receivedAt})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "receivedAt", Value: 1}}).SetLimit(limit)
+	var payloads = make([]*models.RawPayload, 0)
+
+	cursor, err := pas.db.Find(context.Background(), filter, opts)
+	if err != nil {
+		logger.Error("Error fetching archived payloads", "error", err)
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			logger.Error("Error closing cursor", "error", err)
+			return
+		}
+	}(cursor, context.Background())
+
+	for cursor.Next(context.Background()) {
+		var payload models.RawPayload
+		err := cursor.Decode(&payload)
+		if err != nil {
+			logger.Error("Error decoding archived payload", "error", err)
+			return nil, err
+		}
+		payloads = append(payloads, &payload)
+	}
+
+	if err := cursor.Err(); err != nil {
+		logger.Error("Cursor iteration error", "error", err)
+		return nil, err
+	}
+
+	return payloads, nil
+}
+
+func outcomeFields(outcome, errorMessage string) bson.D {
+	return bson.D{
+		{Key: "outcome", Value: outcome},
+		{Key: "error", Value: errorMessage},
+		{Key: "parsedAt", Value: time.Now()},
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"math/big"
+	"solana/models"
+	"solana/utils"
+)
+
+// Cost basis methods of the PnL engine
+const (
+	PnLMethodFIFO    = "fifo"
+	PnLMethodAverage = "average"
+)
+
+// pnlDisplayDecimals is the number of decimals token quantities and SOL amounts are rounded to
+const pnlDisplayDecimals = 9
+
+// PnLService computes the profit and loss of the stored swaps of a wallet. Swaps against SOL open and close
+// positions, token to token swaps close the position in one token and carry its SOL cost over to the other.
+type PnLService struct {
+	transactions *TransactionsService
+	prices       models.PriceSource
+}
+
+// NewPnLService returns a PnL service, the price source may be nil to leave open positions unvalued
+func NewPnLService(transactions DBService, prices models.PriceSource) *PnLService {
+	return &PnLService{transactions: NewTransactionsService(transactions), prices: prices}
+}
+
+// GetWalletPnL returns the PnL of the account for the trades made between from and to, Unix timestamps where
+// zero leaves that side open. Trades before from still build the positions the trades in range close.
+func (ps *PnLService) GetWalletPnL(wallet *models.MonitoredWallet, method string, from, to int64) (*models.WalletPnL, error) {
+	transactions, err := ps.transactions.GetTransactionsByAccountUntil(wallet.PublicKey, to)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := newPnLEngine(method, from)
+	for _, transaction := range transactions {
+		engine.add(transaction)
+	}
+	pnl := engine.result(ps.prices)
+	pnl.Account = wallet.PublicKey
+	pnl.Name = wallet.Name
+	pnl.From = from
+	pnl.To = to
+	return pnl, nil
+}
+
+// lot is a quantity of a token bought together, costUSD is nil when its USD cost is not known
+type lot struct {
+	quantity *big.Rat
+	costSOL  *big.Rat
+	costUSD  *big.Rat
+}
+
+// tokenBook tracks the lots and realized PnL of one token
+type tokenBook struct {
+	mint          string
+	symbol        string
+	lots          []*lot
+	bought        *big.Rat
+	sold          *big.Rat
+	unmatchedSold *big.Rat
+	realizedSOL   *big.Rat
+	realizedUSD   *big.Rat
+	usdComplete   bool
+	closedTrades  int
+	wins          int
+	lastTrade     int64
+}
+
+type pnlEngine struct {
+	method string
+	from   int64
+	books  map[string]*tokenBook
+	mints  []string
+	trades int
+}
+
+func newPnLEngine(method string, from int64) *pnlEngine {
+	if method != PnLMethodAverage {
+		method = PnLMethodFIFO
+	}
+	return &pnlEngine{method: method, from: from, books: make(map[string]*tokenBook)}
+}
+
+func (e *pnlEngine) book(mint, symbol string) *tokenBook {
+	book, ok := e.books[mint]
+	if !ok {
+		book = &tokenBook{
+			mint:          mint,
+			bought:        new(big.Rat),
+			sold:          new(big.Rat),
+			unmatchedSold: new(big.Rat),
+			realizedSOL:   new(big.Rat),
+			realizedUSD:   new(big.Rat),
+			usdComplete:   true,
+		}
+		e.books[mint] = book
+		e.mints = append(e.mints, mint)
+	}
+	if symbol != "" {
+		book.symbol = symbol
+	}
+	return book
+}
+
+// add books the swap, transactions must be added in the order they were made
+func (e *pnlEngine) add(transaction *models.TransactionDetails) {
+	amountOut := parseAmount(transaction.AmountOutDecimal, transaction.AmountOut, transaction.FromTokenDecimal)
+	amountIn := parseAmount(transaction.AmountInDecimal, transaction.AmountIn, transaction.ToTokenDecimal)
+	if amountOut == nil || amountIn == nil || amountOut.Sign() <= 0 || amountIn.Sign() <= 0 {
+		return
+	}
+	// Both sides of a swap are worth the same, either USD value prices the trade
+	valueUSD := parseDecimal(transaction.AmountOutUSD)
+	if valueUSD == nil {
+		valueUSD = parseDecimal(transaction.AmountInUSD)
+	}
+	inRange := transaction.TimeStamp >= e.from
+	if inRange {
+		e.trades++
+	}
+
+	switch {
+	case transaction.FromToken == utils.SOL_ADDRESS:
+		book := e.book(transaction.ToToken, transaction.ToTokenSymbol)
+		e.buy(book, amountIn, amountOut, valueUSD, inRange)
+		book.lastTrade = transaction.TimeStamp
+	case transaction.ToToken == utils.SOL_ADDRESS:
+		book := e.book(transaction.FromToken, transaction.FromTokenSymbol)
+		e.sell(book, amountOut, amountIn, valueUSD, inRange)
+		book.lastTrade = transaction.TimeStamp
+	default:
+		sold := e.book(transaction.FromToken, transaction.FromTokenSymbol)
+		costSOL := e.sell(sold, amountOut, nil, valueUSD, inRange)
+		sold.lastTrade = transaction.TimeStamp
+		bought := e.book(transaction.ToToken, transaction.ToTokenSymbol)
+		e.buy(bought, amountIn, costSOL, valueUSD, inRange)
+		bought.lastTrade = transaction.TimeStamp
+	}
+}
+
+func (e *pnlEngine) buy(book *tokenBook, quantity, costSOL, costUSD *big.Rat, inRange bool) {
+	if inRange {
+		book.bought.Add(book.bought, quantity)
+	}
+	bought := &lot{quantity: new(big.Rat).Set(quantity), costSOL: new(big.Rat).Set(costSOL), costUSD: copyRat(costUSD)}
+	if e.method == PnLMethodAverage && len(book.lots) > 0 {
+		pooled := book.lots[0]
+		pooled.quantity.Add(pooled.quantity, bought.quantity)
+		pooled.costSOL.Add(pooled.costSOL, bought.costSOL)
+		pooled.costUSD = addRats(pooled.costUSD, bought.costUSD)
+		return
+	}
+	book.lots = append(book.lots, bought)
+}
+
+// sell closes the quantity against the lots of the book and returns the SOL cost of the matched part. Without
+// SOL proceeds, for token to token swaps, no SOL PnL is realized and the cost carries over.
+func (e *pnlEngine) sell(book *tokenBook, quantity, proceedsSOL, proceedsUSD *big.Rat, inRange bool) *big.Rat {
+	matched, costSOL, costUSD := book.consume(quantity)
+	if inRange {
+		book.sold.Add(book.sold, quantity)
+		book.unmatchedSold.Add(book.unmatchedSold, new(big.Rat).Sub(quantity, matched))
+	}
+	if matched.Sign() == 0 || !inRange {
+		return costSOL
+	}
+
+	// Only the matched part of the proceeds has a known cost
+	share := new(big.Rat).Quo(matched, quantity)
+	if proceedsSOL != nil {
+		realized := new(big.Rat).Sub(new(big.Rat).Mul(proceedsSOL, share), costSOL)
+		book.realizedSOL.Add(book.realizedSOL, realized)
+		book.closedTrades++
+		if realized.Sign() > 0 {
+			book.wins++
+		}
+	}
+	if proceedsUSD != nil && costUSD != nil {
+		book.realizedUSD.Add(book.realizedUSD, new(big.Rat).Sub(new(big.Rat).Mul(proceedsUSD, share), costUSD))
+	} else {
+		book.usdComplete = false
+	}
+	return costSOL
+}
+
+// consume removes up to the quantity from the lots, oldest first, and returns the quantity removed and its cost
+func (b *tokenBook) consume(quantity *big.Rat) (matched *big.Rat, costSOL *big.Rat, costUSD *big.Rat) {
+	matched, costSOL, costUSD = new(big.Rat), new(big.Rat), new(big.Rat)
+	remaining := new(big.Rat).Set(quantity)
+	for len(b.lots) > 0 && remaining.Sign() > 0 {
+		current := b.lots[0]
+		taken := new(big.Rat).Set(current.quantity)
+		if remaining.Cmp(taken) < 0 {
+			taken.Set(remaining)
+		}
+		share := new(big.Rat).Quo(taken, current.quantity)
+		takenSOL := new(big.Rat).Mul(current.costSOL, share)
+		costSOL.Add(costSOL, takenSOL)
+		current.costSOL.Sub(current.costSOL, takenSOL)
+		if current.costUSD != nil {
+			takenUSD := new(big.Rat).Mul(current.costUSD, share)
+			costUSD = addRats(costUSD, takenUSD)
+			current.costUSD.Sub(current.costUSD, takenUSD)
+		} else {
+			costUSD = nil
+		}
+
+		matched.Add(matched, taken)
+		remaining.Sub(remaining, taken)
+		current.quantity.Sub(current.quantity, taken)
+		if current.quantity.Sign() == 0 {
+			b.lots = b.lots[1:]
+		}
+	}
+	return matched, costSOL, costUSD
+}
+
+func (e *pnlEngine) result(prices models.PriceSource) *models.WalletPnL {
+	pnl := &models.WalletPnL{Method: e.method, Trades: e.trades, USDComplete: true, Tokens: make([]models.TokenPnL, 0, len(e.mints))}
+	realizedSOL, realizedUSD := new(big.Rat), new(big.Rat)
+	unrealizedSOL, unrealizedUSD := new(big.Rat), new(big.Rat)
+	var solPriceUSD *big.Rat
+	if prices != nil {
+		solPriceUSD = priceUSD(prices, utils.SOL_ADDRESS)
+	}
+	valuedSOL, valuedUSD := false, false
+
+	for _, mint := range e.mints {
+		book := e.books[mint]
+		position, costSOL, costUSD := new(big.Rat), new(big.Rat), new(big.Rat)
+		for _, current := range book.lots {
+			position.Add(position, current.quantity)
+			costSOL.Add(costSOL, current.costSOL)
+			costUSD = addRats(costUSD, current.costUSD)
+		}
+
+		token := models.TokenPnL{
+			Mint:               mint,
+			Symbol:             book.symbol,
+			Bought:             utils.FormatRat(book.bought, pnlDisplayDecimals),
+			Sold:               utils.FormatRat(book.sold, pnlDisplayDecimals),
+			UnmatchedSold:      utils.FormatRat(book.unmatchedSold, pnlDisplayDecimals),
+			ClosedTrades:       book.closedTrades,
+			Wins:               book.wins,
+			RealizedSOL:        utils.FormatRat(book.realizedSOL, pnlDisplayDecimals),
+			RealizedUSD:        utils.FormatRat(book.realizedUSD, models.USDDecimals),
+			USDComplete:        book.usdComplete,
+			Position:           utils.FormatRat(position, pnlDisplayDecimals),
+			CostBasisSOL:       utils.FormatRat(costSOL, pnlDisplayDecimals),
+			LastTradeTimeStamp: book.lastTrade,
+		}
+		if costUSD != nil {
+			token.CostBasisUSD = utils.FormatRat(costUSD, models.USDDecimals)
+		}
+
+		if position.Sign() > 0 {
+			pnl.OpenPositions++
+			token.AverageCostSOL = utils.FormatRat(new(big.Rat).Quo(costSOL, position), pnlDisplayDecimals)
+			if price := priceUSD(prices, mint); price != nil {
+				token.CurrentPriceUSD = utils.FormatRat(price, pnlDisplayDecimals)
+				valueUSD := new(big.Rat).Mul(position, price)
+				if costUSD != nil {
+					unrealized := new(big.Rat).Sub(valueUSD, costUSD)
+					token.UnrealizedUSD = utils.FormatRat(unrealized, models.USDDecimals)
+					unrealizedUSD.Add(unrealizedUSD, unrealized)
+					valuedUSD = true
+				}
+				if solPriceUSD != nil && solPriceUSD.Sign() > 0 {
+					unrealized := new(big.Rat).Sub(new(big.Rat).Quo(valueUSD, solPriceUSD), costSOL)
+					token.UnrealizedSOL = utils.FormatRat(unrealized, pnlDisplayDecimals)
+					unrealizedSOL.Add(unrealizedSOL, unrealized)
+					valuedSOL = true
+				}
+			}
+		}
+
+		realizedSOL.Add(realizedSOL, book.realizedSOL)
+		realizedUSD.Add(realizedUSD, book.realizedUSD)
+		pnl.ClosedTrades += book.closedTrades
+		pnl.Wins += book.wins
+		pnl.USDComplete = pnl.USDComplete && book.usdComplete
+		pnl.Tokens = append(pnl.Tokens, token)
+	}
+
+	pnl.RealizedSOL = utils.FormatRat(realizedSOL, pnlDisplayDecimals)
+	pnl.RealizedUSD = utils.FormatRat(realizedUSD, models.USDDecimals)
+	if valuedSOL {
+		pnl.UnrealizedSOL = utils.FormatRat(unrealizedSOL, pnlDisplayDecimals)
+	}
+	if valuedUSD {
+		pnl.UnrealizedUSD = utils.FormatRat(unrealizedUSD, models.USDDecimals)
+	}
+	if pnl.ClosedTrades > 0 {
+		pnl.WinRate = float64(pnl.Wins) / float64(pnl.ClosedTrades)
+	}
+	return pnl
+}
+
+// parseAmount returns the decimal amount, computing it from the raw amount for transactions stored without one
+func parseAmount(amountDecimal, raw string, decimals int) *big.Rat {
+	if amountDecimal == "" {
+		formatted, err := utils.FormatUnits(raw, decimals)
+		if err != nil {
+			return nil
+		}
+		amountDecimal = formatted
+	}
+	return parseDecimal(amountDecimal)
+}
+
+func parseDecimal(decimal string) *big.Rat {
+	if decimal == "" {
+		return nil
+	}
+	number, ok := new(big.Rat).SetString(decimal)
+	if !ok {
+		return nil
+	}
+	return number
+}
+
+func priceUSD(prices models.PriceSource, mint string) *big.Rat {
+	if prices == nil {
+		return nil
+	}
+	price, ok := prices.PriceUSD(mint)
+	if !ok {
+		return nil
+	}
+	return parseDecimal(price)
+}
+
+func copyRat(number *big.Rat) *big.Rat {
+	if number == nil {
+		return nil
+	}
+	return new(big.Rat).Set(number)
+}
+
+// addRats adds two amounts that are nil when unknown, the sum is unknown if either is
+func addRats(a, b *big.Rat) *big.Rat {
+	if a == nil || b == nil {
+		return nil
+	}
+	return new(big.Rat).Add(a, b)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"solana/models"
+	"solana/utils"
+	"testing"
+)
+
+func swap(timeStamp int64, fromToken, amountOut, toToken, amountIn, valueUSD string) *models.TransactionDetails {
+	return &models.TransactionDetails{
+		FromToken:        fromToken,
+		AmountOutDecimal: amountOut,
+		ToToken:          toToken,
+		AmountInDecimal:  amountIn,
+		AmountOutUSD:     valueUSD,
+		TimeStamp:        timeStamp,
+	}
+}
+
+// raydiumSwap is a swap read from the swap event, as the RAYDIUM and ORCA parsers do
+func raydiumSwap(t *testing.T, timeStamp int64, fromToken string, rawAmountOut string, toToken string, rawAmountIn string) *models.TransactionDetails {
+	payload := models.SolanaPayload{
+		FeePayer:  "alice",
+		Source:    "RAYDIUM",
+		Timestamp: timeStamp,
+		Events: map[string]models.SwapEvent{"swap": {
+			TokenInputs:  []models.TokenIO{{Mint: fromToken, RawTokenAmount: models.RawTokenAmount{TokenAmount: rawAmountOut}}},
+			TokenOutputs: []models.TokenIO{{Mint: toToken, RawTokenAmount: models.RawTokenAmount{TokenAmount: rawAmountIn}}},
+		}},
+	}
+	from, to := payload.SwapEventSides()
+	transaction, err := payload.NewTransactionDetails(1, from, to, models.Resolvers{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return &transaction
+}
+
+func TestPnLEngine(t *testing.T) {
+	transactions := []*models.TransactionDetails{
+		swap(1, utils.SOL_ADDRESS, "1", "BONK", "100", "100"),
+		swap(2, utils.SOL_ADDRESS, "2", "BONK", "100", "200"),
+		swap(3, "BONK", "150", utils.SOL_ADDRESS, "3", "300"),
+		swap(4, "BONK", "100", utils.SOL_ADDRESS, "1", "100"),
+	}
+	run := func(method string, from int64) *models.WalletPnL {
+		engine := newPnLEngine(method, from)
+		for _, transaction := range transactions {
+			engine.add(transaction)
+		}
+		return engine.result(nil)
+	}
+
+	t.Run("Matches sells against the oldest buys with FIFO", func(t *testing.T) {
+		pnl := run(PnLMethodFIFO, 0)
+		if pnl.RealizedSOL != "0.5" || pnl.RealizedUSD != "50" {
+			t.Errorf("Incorrect realized PnL %s SOL %s USD", pnl.RealizedSOL, pnl.RealizedUSD)
+		}
+		if pnl.ClosedTrades != 2 || pnl.Wins != 1 || pnl.WinRate != 0.5 {
+			t.Errorf("Incorrect win rate %d/%d", pnl.Wins, pnl.ClosedTrades)
+		}
+		token := pnl.Tokens[0]
+		if token.UnmatchedSold != "50" || token.Position != "0" || pnl.OpenPositions != 0 {
+			t.Errorf("Incorrect position: %+v", token)
+		}
+	})
+	t.Run("Uses the pooled cost with average cost", func(t *testing.T) {
+		pnl := run(PnLMethodAverage, 0)
+		// 2.25 SOL cost for the first sell, the remaining 50 tokens cost 0.75 SOL and sell for 0.5 SOL
+		if pnl.RealizedSOL != "0.5" || pnl.Tokens[0].RealizedSOL != "0.5" {
+			t.Errorf("Incorrect realized PnL %s SOL", pnl.RealizedSOL)
+		}
+		if pnl.Wins != 1 {
+			t.Errorf("Expected 1 win, got %d", pnl.Wins)
+		}
+	})
+	t.Run("Keeps the open position", func(t *testing.T) {
+		engine := newPnLEngine(PnLMethodAverage, 0)
+		for _, transaction := range transactions[:3] {
+			engine.add(transaction)
+		}
+		pnl := engine.result(nil)
+		token := pnl.Tokens[0]
+		if pnl.RealizedSOL != "0.75" || token.Position != "50" || token.CostBasisSOL != "0.75" || token.AverageCostSOL != "0.015" {
+			t.Errorf("Incorrect position: realized %s, %+v", pnl.RealizedSOL, token)
+		}
+	})
+	t.Run("Only realizes the trades in range", func(t *testing.T) {
+		pnl := run(PnLMethodFIFO, 3)
+		if pnl.Trades != 2 || pnl.Tokens[0].Bought != "0" || pnl.RealizedSOL != "0.5" {
+			t.Errorf("Incorrect PnL in range: %d trades, bought %s, realized %s", pnl.Trades, pnl.Tokens[0].Bought, pnl.RealizedSOL)
+		}
+	})
+	t.Run("Carries the SOL cost over token to token swaps", func(t *testing.T) {
+		engine := newPnLEngine(PnLMethodFIFO, 0)
+		engine.add(swap(1, utils.SOL_ADDRESS, "1", "BONK", "100", ""))
+		engine.add(swap(2, "BONK", "100", "WIF", "10", ""))
+		engine.add(swap(3, "WIF", "10", utils.SOL_ADDRESS, "1.5", ""))
+		pnl := engine.result(nil)
+		if pnl.RealizedSOL != "0.5" || pnl.USDComplete {
+			t.Errorf("Incorrect realized PnL %s SOL, USD complete %t", pnl.RealizedSOL, pnl.USDComplete)
+		}
+	})
+	t.Run("Books swaps read from the swap event", func(t *testing.T) {
+		engine := newPnLEngine(PnLMethodFIFO, 0)
+		engine.add(raydiumSwap(t, 1, utils.SOL_ADDRESS, "1", "TOK", "5"))
+		engine.add(raydiumSwap(t, 2, "TOK", "5", utils.SOL_ADDRESS, "2"))
+		pnl := engine.result(nil)
+		token := pnl.Tokens[0]
+		if token.Mint != "TOK" || token.Bought != "5" || pnl.RealizedSOL != "1" {
+			t.Errorf("Incorrect PnL: bought %s %s, realized %s SOL", token.Bought, token.Mint, pnl.RealizedSOL)
+		}
+	})
+}
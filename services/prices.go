@@ -0,0 +1,53 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// PriceFetcher looks up the USD prices of mints, leaving out the ones it has no price for
+type PriceFetcher interface {
+	GetPrices(mints []string) (map[string]string, error)
+}
+
+type cachedPrice struct {
+	price     string
+	ok        bool
+	fetchedAt time.Time
+}
+
+// CachedPriceSource implements models.PriceSource on top of a price fetcher, keeping every price, and every
+// mint without one, for the time to live so busy tokens are not looked up for each transaction
+type CachedPriceSource struct {
+	sync.Mutex
+	fetcher PriceFetcher
+	ttl     time.Duration
+	prices  map[string]cachedPrice
+}
+
+func NewCachedPriceSource(fetcher PriceFetcher, ttl time.Duration) *CachedPriceSource {
+	return &CachedPriceSource{fetcher: fetcher, ttl: ttl, prices: make(map[string]cachedPrice)}
+}
+
+// PriceUSD returns the cached price of the mint, fetching it when it is missing or expired. A failed fetch
+// reports the price as unknown without caching it.
+func (cps *CachedPriceSource) PriceUSD(mint string) (string, bool) {
+	cps.Lock()
+	cached, found := cps.prices[mint]
+	cps.Unlock()
+	if found && time.Since(cached.fetchedAt) < cps.ttl {
+		return cached.price, cached.ok
+	}
+
+	prices, err := cps.fetcher.GetPrices([]string{mint})
+	if err != nil {
+		logger.Error("Error fetching price", "error", err, "mint", mint)
+		return "", false
+	}
+	price, ok := prices[mint]
+
+	cps.Lock()
+	cps.prices[mint] = cachedPrice{price: price, ok: ok, fetchedAt: time.Now()}
+	cps.Unlock()
+	return price, ok
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IDSequence hands out increasing event IDs
+type IDSequence interface {
+	// Continue makes sure the next ID is greater than the given one, which is the latest ID already in use
+	Continue(latestID int64) error
+	NextID() (int64, error)
+}
+
+// MongoSequence is an IDSequence kept in a counter document, so every instance sharing the database draws its
+// event IDs from the same sequence
+type MongoSequence struct {
+	db   DBService
+	name string
+}
+
+type sequenceCounter struct {
+	Name  string `bson:"_id"`
+	Value int64  `bson:"value"`
+}
+
+func NewMongoSequence(db DBService, name string) *MongoSequence {
+	return &MongoSequence{db: db, name: name}
+}
+
+func (ms *MongoSequence) Continue(latestID int64) error {
+	opts := options.Update().SetUpsert(true)
+	_, err := ms.db.UpdateOne(context.Background(), bson.D{{Key: "_id", Value: ms.name}}, bson.D{{Key: "$max", Value: bson.D{{Key: "value", Value: latestID}}}}, opts)
+	if err != nil {
+		logger.Error("Error continuing ID sequence", "error", err, "name", ms.name)
+	}
+	return err
+}
+
+func (ms *MongoSequence) NextID() (int64, error) {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter sequenceCounter
+	err := ms.db.FindOneAndUpdate(context.Background(), bson.D{{Key: "_id", Value: ms.name}}, bson.D{{Key: "$inc", Value: bson.D{{Key: "value", Value: 1}}}}, opts).Decode(&counter)
+	if err != nil {
+		logger.Error("Error getting next ID", "error", err, "name", ms.name)
+		return 0, err
+	}
+	return counter.Value, nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"solana/models"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokenMissRetryInterval is how long a mint whose metadata could not be found is not looked up again
+const tokenMissRetryInterval = 10 * time.Minute
+
+// TokenMetadataFetcher looks up the metadata of a mint outside of the cache, for example on chain
+type TokenMetadataFetcher interface {
+	FetchTokenMetadata(mint string) (*models.TokenMetadata, error)
+}
+
+// TokenLogoFetcher reads the logo of a token from its off-chain metadata document
+type TokenLogoFetcher interface {
+	FetchLogo(uri string) (string, error)
+}
+
+// TokenMetadataService resolves mints to their metadata from memory, then the tokens collection and finally
+// the fetcher, storing what it fetched. It implements models.TokenResolver. Logos are only fetched by
+// GetTokenWithLogo, so resolving tokens while ingesting never reads off-chain documents.
+type TokenMetadataService struct {
+	sync.RWMutex
+	db         DBService
+	fetcher    TokenMetadataFetcher
+	tokens     map[string]models.TokenMetadata
+	misses     map[string]time.Time
+	logoMisses map[string]time.Time
+}
+
+// NewTokenMetadataService returns a token metadata service, the fetcher may be nil to only use stored metadata
+func NewTokenMetadataService(db DBService, fetcher TokenMetadataFetcher) *TokenMetadataService {
+	return &TokenMetadataService{
+		db:         db,
+		fetcher:    fetcher,
+		tokens:     make(map[string]models.TokenMetadata),
+		misses:     make(map[string]time.Time),
+		logoMisses: make(map[string]time.Time),
+	}
+}
+
+func (tms *TokenMetadataService) EnsureIndexes() error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "mint", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	_, err := tms.db.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		logger.Error("Error creating token indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// SeedFromFile stores the tokens of a JSON list of token metadata, overwriting what is stored for their mints
+func (tms *TokenMetadataService) SeedFromFile(path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error("Error reading token list", "error", err, "path", path)
+		return err
+	}
+	var tokens []models.TokenMetadata
+	err = json.Unmarshal(body, &tokens)
+	if err != nil {
+		logger.Error("Error unmarshalling token list", "error", err, "path", path)
+		return err
+	}
+
+	for _, token := range tokens {
+		token.Source = models.TokenSourceSeed
+		err = tms.SaveTokenMetadata(&token)
+		if err != nil {
+			return err
+		}
+	}
+	logger.Info("Seeded token metadata", "path", path, "tokens", len(tokens))
+	return nil
+}
+
+// SaveTokenMetadata stores the metadata of the mint and caches it
+func (tms *TokenMetadataService) SaveTokenMetadata(token *models.TokenMetadata) error {
+	token.UpdatedAt = time.Now()
+	opts := options.FindOneAndReplace().SetUpsert(true)
+	result := tms.db.FindOneAndReplace(context.Background(), bson.D{{Key: "mint", Value: token.Mint}}, token, opts)
+	// Upserting a new mint finds no document to return
+	if result.Err() != nil && result.Err() != mongo.ErrNoDocuments {
+		logger.Error("Error saving token metadata", "error", result.Err(), "mint", token.Mint)
+		return result.Err()
+	}
+	tms.cache(*token)
+	return nil
+}
+
+// GetTokenMetadata returns the metadata of the mint, or nil if it could not be found anywhere
+func (tms *TokenMetadataService) GetTokenMetadata(mint string) (*models.TokenMetadata, error) {
+	tms.RLock()
+	token, ok := tms.tokens[mint]
+	missedAt, missed := tms.misses[mint]
+	tms.RUnlock()
+	if ok {
+		return &token, nil
+	}
+	if missed && time.Since(missedAt) < tokenMissRetryInterval {
+		return nil, nil
+	}
+
+	stored, err := tms.findTokenMetadata(mint)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		tms.cache(*stored)
+		return stored, nil
+	}
+
+	if tms.fetcher == nil {
+		tms.miss(mint)
+		return nil, nil
+	}
+	fetched, err := tms.fetcher.FetchTokenMetadata(mint)
+	if err != nil {
+		tms.miss(mint)
+		return nil, err
+	}
+	err = tms.SaveTokenMetadata(fetched)
+	if err != nil {
+		return nil, err
+	}
+	return fetched, nil
+}
+
+// GetTokenWithLogo returns the metadata of the mint like GetTokenMetadata, reading the logo from the off-chain
+// metadata when the token has none yet and the fetcher is a TokenLogoFetcher
+func (tms *TokenMetadataService) GetTokenWithLogo(mint string) (*models.TokenMetadata, error) {
+	token, err := tms.GetTokenMetadata(mint)
+	if err != nil || token == nil || token.Logo != "" || token.URI == "" {
+		return token, err
+	}
+	logos, ok := tms.fetcher.(TokenLogoFetcher)
+	if !ok {
+		return token, nil
+	}
+
+	tms.RLock()
+	missedAt, missed := tms.logoMisses[mint]
+	tms.RUnlock()
+	if missed && time.Since(missedAt) < tokenMissRetryInterval {
+		return token, nil
+	}
+
+	logo, err := logos.FetchLogo(token.URI)
+	if err != nil || logo == "" {
+		tms.Lock()
+		tms.logoMisses[mint] = time.Now()
+		tms.Unlock()
+		return token, nil
+	}
+	token.Logo = logo
+	// The logo is still served when storing it fails, SaveTokenMetadata logs the error
+	_ = tms.SaveTokenMetadata(token)
+	return token, nil
+}
+
+// LookupToken returns the metadata of the mint, logging instead of returning lookup errors
+func (tms *TokenMetadataService) LookupToken(mint string) (models.TokenMetadata, bool) {
+	token, err := tms.GetTokenMetadata(mint)
+	if err != nil {
+		logger.Error("Error looking up token metadata", "error", err, "mint", mint)
+		return models.TokenMetadata{}, false
+	}
+	if token == nil {
+		return models.TokenMetadata{}, false
+	}
+	return *token, true
+}
+
+func (tms *TokenMetadataService) findTokenMetadata(mint string) (*models.TokenMetadata, error) {
+	var token models.TokenMetadata
+
+	result := tms.db.FindOne(context.Background(), bson.D{{Key: "mint", Value: mint}})
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		logger.Error("Error finding token metadata", "error", result.Err(), "mint", mint)
+		return nil, result.Err()
+	}
+
+	err := result.Decode(&token)
+	if err != nil {
+		logger.Error("Error decoding token metadata", "error", err)
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (tms *TokenMetadataService) cache(token models.TokenMetadata) {
+	tms.Lock()
+	defer tms.Unlock()
+	tms.tokens[token.Mint] = token
+	delete(tms.misses, token.Mint)
+}
+
+func (tms *TokenMetadataService) miss(mint string) {
+	tms.Lock()
+	defer tms.Unlock()
+	tms.misses[mint] = time.Now()
+}
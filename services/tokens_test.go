@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"solana/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tokensCollection accepts every stored token
+type tokensCollection struct {
+	DBService
+	saved int
+}
+
+func (tc *tokensCollection) FindOneAndReplace(ctx context.Context, filter interface{}, replacement interface{}, opts ...*options.FindOneAndReplaceOptions) *mongo.SingleResult {
+	tc.saved++
+	return mongo.NewSingleResultFromDocument(bson.D{}, nil, nil)
+}
+
+// logoFetcher answers every off-chain metadata document with logo, or with err when it is set
+type logoFetcher struct {
+	logo    string
+	err     error
+	fetched int
+}
+
+func (lf *logoFetcher) FetchTokenMetadata(mint string) (*models.TokenMetadata, error) {
+	return nil, errors.New("not on chain")
+}
+
+func (lf *logoFetcher) FetchLogo(uri string) (string, error) {
+	lf.fetched++
+	return lf.logo, lf.err
+}
+
+func TestGetTokenWithLogo(t *testing.T) {
+	token := models.TokenMetadata{Mint: "bonk", Symbol: "BONK", URI: "https://arweave.net/bonk"}
+
+	t.Run("resolving tokens does not fetch the logo", func(t *testing.T) {
+		fetcher := &logoFetcher{logo: "https://arweave.net/bonk.png"}
+		tokens := NewTokenMetadataService(&tokensCollection{}, fetcher)
+		tokens.cache(token)
+
+		resolved, ok := tokens.LookupToken("bonk")
+		if !ok || resolved.Logo != "" {
+			t.Errorf("Incorrect token %+v should have no logo", resolved)
+		}
+		if fetcher.fetched != 0 {
+			t.Errorf("Incorrect fetches %v should be 0", fetcher.fetched)
+		}
+	})
+
+	t.Run("fetches the logo once and stores it", func(t *testing.T) {
+		fetcher := &logoFetcher{logo: "https://arweave.net/bonk.png"}
+		collection := &tokensCollection{}
+		tokens := NewTokenMetadataService(collection, fetcher)
+		tokens.cache(token)
+
+		for i := 0; i < 2; i++ {
+			resolved, err := tokens.GetTokenWithLogo("bonk")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resolved.Logo != fetcher.logo {
+				t.Errorf("Incorrect logo %q should be %q", resolved.Logo, fetcher.logo)
+			}
+		}
+		if fetcher.fetched != 1 || collection.saved != 1 {
+			t.Errorf("Incorrect fetches %v and saves %v should be 1", fetcher.fetched, collection.saved)
+		}
+	})
+
+	t.Run("does not retry a failed logo right away", func(t *testing.T) {
+		fetcher := &logoFetcher{err: errors.New("refused")}
+		tokens := NewTokenMetadataService(&tokensCollection{}, fetcher)
+		tokens.cache(token)
+
+		for i := 0; i < 2; i++ {
+			resolved, err := tokens.GetTokenWithLogo("bonk")
+			if err != nil || resolved == nil {
+				t.Fatalf("Incorrect result %v, %v should be the token without logo", resolved, err)
+			}
+		}
+		if fetcher.fetched != 1 {
+			t.Errorf("Incorrect fetches %v should be 1", fetcher.fetched)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"solana/models"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultTransactionPageSize = 100
+	MaxTransactionPageSize     = 1000
+)
+
+var (
+	ErrInvalidCursor           = errors.New("invalid cursor")
+	ErrInvalidTransactionQuery = errors.New("invalid transaction query")
+)
+
+// transactionFields are the stored field names of a transaction, which are also its JSON names
+var transactionFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	transactionType := reflect.TypeOf(models.TransactionDetails{})
+	for i := 0; i < transactionType.NumField(); i++ {
+		name := strings.Split(transactionType.Field(i).Tag.Get("bson"), ",")[0]
+		fields[name] = true
+	}
+	return fields
+}()
+
+// IsTransactionField tells whether the name is the name of a stored transaction field
+func IsTransactionField(name string) bool {
+	return transactionFields[name]
+}
+
+// TransactionCursor is the position after the last transaction of a page. Transactions are ordered by
+// timestamp and then ID, which is unique.
+type TransactionCursor struct {
+	TimeStamp int64
+	ID        int64
+}
+
+func (tc TransactionCursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", tc.TimeStamp, tc.ID)))
+}
+
+func DecodeTransactionCursor(cursor string) (*TransactionCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var transactionCursor TransactionCursor
+	_, err = fmt.Sscanf(string(decoded), "%d:%d", &transactionCursor.TimeStamp, &transactionCursor.ID)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &transactionCursor, nil
+}
+
+// TransactionQuery selects a page of stored transactions, empty fields match everything. MinAmount is a decimal
+// amount of Mint, which it requires, on whichever side of the swap Mint is.
+type TransactionQuery struct {
+	Account     string
+	AccountName string
+	Mint        string
+	Status      string
+	From        int64
+	To          int64
+	MinAmount   string
+	Ascending   bool
+	After       *TransactionCursor
+	Limit       int64
+	Fields      []string
+}
+
+// QueryTransactions returns a page of the transactions matching the query and the cursor of the next page,
+// which is nil on the last page. With fields selected only those are read, along with the ones the cursor needs.
+func (ts *TransactionsService) QueryTransactions(query TransactionQuery) ([]*models.TransactionDetails, *TransactionCursor, error) {
+	filter, err := query.filter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	direction := -1
+	if query.Ascending {
+		direction = 1
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > MaxTransactionPageSize {
+		limit = DefaultTransactionPageSize
+	}
+	// Reading one more than the page tells whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timeStamp", Value: direction}, {Key: "id", Value: direction}}).
+		SetLimit(limit + 1)
+	if len(query.Fields) > 0 {
+		projection := bson.D{{Key: "_id", Value: 0}, {Key: "id", Value: 1}, {Key: "timeStamp", Value: 1}}
+		for _, field := range query.Fields {
+			if field != "id" && field != "timeStamp" {
+				projection = append(projection, bson.E{Key: field, Value: 1})
+			}
+		}
+		opts.SetProjection(projection)
+	}
+
+	transactions, err := ts.find(filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(transactions)) <= limit {
+		return transactions, nil, nil
+	}
+	transactions = transactions[:limit]
+	last := transactions[len(transactions)-1]
+	return transactions, &TransactionCursor{TimeStamp: last.TimeStamp, ID: last.ID}, nil
+}
+
+func (query TransactionQuery) filter() (bson.D, error) {
+	filter := bson.D{}
+	if query.Account != "" {
+		filter = append(filter, bson.E{Key: "account", Value: query.Account})
+	}
+	if query.AccountName != "" {
+		filter = append(filter, bson.E{Key: "accountName", Value: query.AccountName})
+	}
+	if query.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: query.Status})
+	}
+	timeStamp := bson.D{}
+	if query.From > 0 {
+		timeStamp = append(timeStamp, bson.E{Key: "$gte", Value: query.From})
+	}
+	if query.To > 0 {
+		timeStamp = append(timeStamp, bson.E{Key: "$lte", Value: query.To})
+	}
+	if len(timeStamp) > 0 {
+		filter = append(filter, bson.E{Key: "timeStamp", Value: timeStamp})
+	}
+
+	conditions := bson.A{}
+	if query.Mint != "" {
+		fromSide := bson.D{{Key: "fromToken", Value: query.Mint}}
+		toSide := bson.D{{Key: "toToken", Value: query.Mint}}
+		if query.MinAmount != "" {
+			minAmount, err := primitive.ParseDecimal128(query.MinAmount)
+			if err != nil {
+				return nil, fmt.Errorf("%w: minimum amount %q is not a decimal", ErrInvalidTransactionQuery, query.MinAmount)
+			}
+			fromSide = append(fromSide, bson.E{Key: "$expr", Value: decimalAtLeast("$amountOutDecimal", minAmount)})
+			toSide = append(toSide, bson.E{Key: "$expr", Value: decimalAtLeast("$amountInDecimal", minAmount)})
+		}
+		conditions = append(conditions, bson.D{{Key: "$or", Value: bson.A{fromSide, toSide}}})
+	} else if query.MinAmount != "" {
+		return nil, fmt.Errorf("%w: minimum amount requires a mint", ErrInvalidTransactionQuery)
+	}
+	if query.After != nil {
+		comparison := "$lt"
+		if query.Ascending {
+			comparison = "$gt"
+		}
+		conditions = append(conditions, bson.D{{Key: "$or", Value: bson.A{
+			bson.D{{Key: "timeStamp", Value: bson.D{{Key: comparison, Value: query.After.TimeStamp}}}},
+			bson.D{
+				{Key: "timeStamp", Value: query.After.TimeStamp},
+				{Key: "id", Value: bson.D{{Key: comparison, Value: query.After.ID}}},
+			},
+		}}})
+	}
+	if len(conditions) > 0 {
+		filter = append(filter, bson.E{Key: "$and", Value: conditions})
+	}
+	return filter, nil
+}
+
+// decimalAtLeast compares a stored decimal string with the minimum, amounts that are not decimals count as zero
+func decimalAtLeast(field string, minimum primitive.Decimal128) bson.D {
+	amount := bson.D{{Key: "$convert", Value: bson.D{
+		{Key: "input", Value: field},
+		{Key: "to", Value: "decimal"},
+		{Key: "onError", Value: 0},
+		{Key: "onNull", Value: 0},
+	}}}
+	return bson.D{{Key: "$gte", Value: bson.A{amount, minimum}}}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"solana/utils"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTransactionCursor(t *testing.T) {
+	t.Run("Decodes an encoded cursor", func(t *testing.T) {
+		cursor := TransactionCursor{TimeStamp: 1700000000, ID: 42}
+		decoded, err := DecodeTransactionCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *decoded != cursor {
+			t.Errorf("Incorrect cursor %+v should be %+v", *decoded, cursor)
+		}
+	})
+	t.Run("Rejects a malformed cursor", func(t *testing.T) {
+		if _, err := DecodeTransactionCursor("not a cursor"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
+
+func TestTransactionQueryFilter(t *testing.T) {
+	t.Run("Requires a mint for the minimum amount", func(t *testing.T) {
+		_, err := TransactionQuery{MinAmount: "10"}.filter()
+		if !errors.Is(err, ErrInvalidTransactionQuery) {
+			t.Errorf("Expected ErrInvalidTransactionQuery, got %v", err)
+		}
+	})
+	t.Run("Rejects a minimum amount that is not a decimal", func(t *testing.T) {
+		_, err := TransactionQuery{Mint: "BONK", MinAmount: "ten"}.filter()
+		if !errors.Is(err, ErrInvalidTransactionQuery) {
+			t.Errorf("Expected ErrInvalidTransactionQuery, got %v", err)
+		}
+	})
+	t.Run("Combines the mint and the cursor", func(t *testing.T) {
+		filter, err := TransactionQuery{Account: "alice", Mint: "BONK", MinAmount: "10", After: &TransactionCursor{TimeStamp: 1, ID: 2}}.filter()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(filter) != 2 || filter[0].Key != "account" || filter[1].Key != "$and" {
+			t.Errorf("Unexpected filter %v", filter)
+		}
+	})
+	t.Run("Compares the minimum amount with the amount of the mint", func(t *testing.T) {
+		filter, err := TransactionQuery{Mint: "TOK", MinAmount: "5"}.filter()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, _ := bson.Marshal(raydiumSwap(t, 1, utils.SOL_ADDRESS, "1", "TOK", "5"))
+		var stored bson.M
+		_ = bson.Unmarshal(data, &stored)
+
+		// Every side of the mint condition pairs a mint field with the amount field it compares
+		sides := filter[0].Value.(bson.A)[0].(bson.D)[0].Value.(bson.A)
+		for _, side := range sides {
+			side := side.(bson.D)
+			if stored[side[0].Key] != "TOK" {
+				continue
+			}
+			comparison := side[1].Value.(bson.D)[0].Value.(bson.A)
+			field := comparison[0].(bson.D)[0].Value.(bson.D)[0].Value.(string)
+			if amount := stored[strings.TrimPrefix(field, "$")]; amount != "5" {
+				t.Errorf("Incorrect amount %v in %s should be the 5 TOK received", amount, field)
+			}
+			return
+		}
+		t.Error("Expected a side matching the mint")
+	})
+}
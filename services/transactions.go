@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"solana/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrDuplicateTransaction is returned when a transaction with the same signature is already stored
+var ErrDuplicateTransaction = errors.New("transaction already stored")
+
+type TransactionsService struct {
+	db DBService
+}
+
+func NewTransactionsService(db DBService) *TransactionsService {
+	return &TransactionsService{db: db}
+}
+
+// EnsureIndexes creates the indexes used by the transaction queries. It is safe to call on every startup.
+func (ts *TransactionsService) EnsureIndexes() error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "signature", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "account", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "timeStamp", Value: -1}, {Key: "id", Value: -1}}},
+		{Keys: bson.D{{Key: "accountName", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "fromToken", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "toToken", Value: 1}, {Key: "timeStamp", Value: -1}}},
+	}
+	_, err := ts.db.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		logger.Error("Error creating transaction indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// SaveTransaction stores the transaction, returning ErrDuplicateTransaction if its signature is already stored
+func (ts *TransactionsService) SaveTransaction(transaction *models.TransactionDetails) error {
+	_, err := ts.db.InsertOne(context.Background(), transaction)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateTransaction
+		}
+		logger.Error("Error inserting transaction", "error", err, "signature", transaction.Signature)
+		return err
+	}
+	return nil
+}
+
+// ReplaceTransaction overwrites the stored transaction with the same signature
+func (ts *TransactionsService) ReplaceTransaction(transaction *models.TransactionDetails) error {
+	result := ts.db.FindOneAndReplace(context.Background(), bson.D{{Key: "signature", Value: transaction.Signature}}, transaction)
+	if result.Err() != nil {
+		logger.Error("Error replacing transaction", "error", result.Err(), "signature", transaction.Signature)
+		return result.Err()
+	}
+	return nil
+}
+
+func (ts *TransactionsService) GetTransactionBySignature(signature string) (*models.TransactionDetails, error) {
+	var transaction models.TransactionDetails
+
+	result := ts.db.FindOne(context.Background(), bson.D{{Key: "signature", Value: signature}})
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		logger.Error("Error finding transaction", "error", result.Err(), "signature", signature)
+		return nil, result.Err()
+	}
+
+	err := result.Decode(&transaction)
+	if err != nil {
+		logger.Error("Error decoding transaction", "error", err)
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// GetTransactionsByAccount returns the latest transactions made by the given account, newest first.
+func (ts *TransactionsService) GetTransactionsByAccount(account string, limit int64) ([]*models.TransactionDetails, error) {
+	filter := bson.D{{Key: "account", Value: account}}
+	opts := options.Find().SetSort(bson.D{{Key: "timeStamp", Value: -1}}).SetLimit(limit)
+	return ts.find(filter, opts)
+}
+
+// GetTransactionsByMint returns the latest transactions that swapped from or to the given mint, newest first.
+func (ts *TransactionsService) GetTransactionsByMint(mint string, limit int64) ([]*models.TransactionDetails, error) {
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "fromToken", Value: mint}},
+		bson.D{{Key: "toToken", Value: mint}},
+	}}}
+	opts := options.Find().SetSort(bson.D{{Key: "timeStamp", Value: -1}}).SetLimit(limit)
+	return ts.find(filter, opts)
+}
+
+// GetTransactionsByAccountUntil returns the transactions made by the given account up to the Unix timestamp, in
+// the order they were made. A zero timestamp returns all of them.
+func (ts *TransactionsService) GetTransactionsByAccountUntil(account string, to int64) ([]*models.TransactionDetails, error) {
+	filter := bson.D{{Key: "account", Value: account}}
+	if to > 0 {
+		filter = append(filter, bson.E{Key: "timeStamp", Value: bson.D{{Key: "$lte", Value: to}}})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "timeStamp", Value: 1}, {Key: "id", Value: 1}})
+	return ts.find(filter, opts)
+}
+
+// GetTransactionsAfterID returns the latest limit stored transactions with an ID greater than the given one, in
+// ID order.
+func (ts *TransactionsService) GetTransactionsAfterID(ID int64, limit int64) ([]*models.TransactionDetails, error) {
+	filter := bson.D{{Key: "id", Value: bson.D{{Key: "$gt", Value: ID}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}}).SetLimit(limit)
+	transactions, err := ts.find(filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(transactions)-1; i < j; i, j = i+1, j-1 {
+		transactions[i], transactions[j] = transactions[j], transactions[i]
+	}
+	return transactions, nil
+}
+
+// GetLatestID returns the highest stored transaction ID, or -1 when nothing has been stored yet.
+func (ts *TransactionsService) GetLatestID() (int64, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})
+	result := ts.db.FindOne(context.Background(), bson.D{}, opts)
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return -1, nil
+		}
+		logger.Error("Error finding latest transaction", "error", result.Err())
+		return -1, result.Err()
+	}
+
+	var transaction models.TransactionDetails
+	err := result.Decode(&transaction)
+	if err != nil {
+		logger.Error("Error decoding transaction", "error", err)
+		return -1, err
+	}
+	return transaction.ID, nil
+}
+
+func (ts *TransactionsService) find(filter interface{}, opts *options.FindOptions) ([]*models.TransactionDetails, error) {
+	var transactions = make([]*models.TransactionDetails, 0)
+
+	cursor, err := ts.db.Find(context.Background(), filter, opts)
+	if err != nil {
+		logger.Error("Error fetching transactions", "error", err)
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			logger.Error("Error closing cursor", "error", err)
+			return
+		}
+	}(cursor, context.Background())
+
+	for cursor.Next(context.Background()) {
+		var transaction models.TransactionDetails
+		err := cursor.Decode(&transaction)
+		if err != nil {
+			logger.Error("Error decoding transaction", "error", err)
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	if err := cursor.Err(); err != nil {
+		logger.Error("Cursor iteration error", "error", err)
+		return nil, err
+	}
+
+	return transactions, nil
+}
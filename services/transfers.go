@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"solana/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type TransfersService struct {
+	db DBService
+}
+
+func NewTransfersService(db DBService) *TransfersService {
+	return &TransfersService{db: db}
+}
+
+// EnsureIndexes creates the indexes used by the transfer queries. It is safe to call on every startup.
+func (ts *TransfersService) EnsureIndexes() error {
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "id", Value: 1}}},
+		{Keys: bson.D{{Key: "signature", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "account", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "transfers.from", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "transfers.to", Value: 1}, {Key: "timeStamp", Value: -1}}},
+		{Keys: bson.D{{Key: "transfers.mint", Value: 1}, {Key: "timeStamp", Value: -1}}},
+	}
+	_, err := ts.db.Indexes().CreateMany(context.Background(), indexes)
+	if err != nil {
+		logger.Error("Error creating transfer indexes", "error", err)
+		return err
+	}
+	return nil
+}
+
+// SaveTransfer stores the transfer, returning ErrDuplicateTransaction if its signature is already stored
+func (ts *TransfersService) SaveTransfer(transfer *models.TransferDetails) error {
+	_, err := ts.db.InsertOne(context.Background(), transfer)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateTransaction
+		}
+		logger.Error("Error inserting transfer", "error", err, "signature", transfer.Signature)
+		return err
+	}
+	return nil
+}
+
+// ReplaceTransfer overwrites the stored transfer with the same signature
+func (ts *TransfersService) ReplaceTransfer(transfer *models.TransferDetails) error {
+	result := ts.db.FindOneAndReplace(context.Background(), bson.D{{Key: "signature", Value: transfer.Signature}}, transfer)
+	if result.Err() != nil {
+		logger.Error("Error replacing transfer", "error", result.Err(), "signature", transfer.Signature)
+		return result.Err()
+	}
+	return nil
+}
+
+func (ts *TransfersService) GetTransferBySignature(signature string) (*models.TransferDetails, error) {
+	var transfer models.TransferDetails
+
+	result := ts.db.FindOne(context.Background(), bson.D{{Key: "signature", Value: signature}})
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		logger.Error("Error finding transfer", "error", result.Err(), "signature", signature)
+		return nil, result.Err()
+	}
+
+	err := result.Decode(&transfer)
+	if err != nil {
+		logger.Error("Error decoding transfer", "error", err)
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+// GetTransfersAfterID returns the latest limit stored transfers with an ID greater than the given one, in ID order.
+func (ts *TransfersService) GetTransfersAfterID(ID int64, limit int64) ([]*models.TransferDetails, error) {
+	filter := bson.D{{Key: "id", Value: bson.D{{Key: "$gt", Value: ID}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "id", Value: -1}}).SetLimit(limit)
+
+	var transfers = make([]*models.TransferDetails, 0)
+	cursor, err := ts.db.Find(context.Background(), filter, opts)
+	if err != nil {
+		logger.Error("Error fetching transfers", "error", err)
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		err := cursor.Close(ctx)
+		if err != nil {
+			logger.Error("Error closing cursor", "error", err)
+			return
+		}
+	}(cursor, context.Background())
+
+	for cursor.Next(context.Background()) {
+		var transfer models.TransferDetails
+		err := cursor.Decode(&transfer)
+		if err != nil {
+			logger.Error("Error decoding transfer", "error", err)
+			return nil, err
+		}
+		transfers = append(transfers, &transfer)
+	}
+
+	if err := cursor.Err(); err != nil {
+		logger.Error("Cursor iteration error", "error", err)
+		return nil, err
+	}
+
+	for i, j := 0, len(transfers)-1; i < j; i, j = i+1, j-1 {
+		transfers[i], transfers[j] = transfers[j], transfers[i]
+	}
+	return transfers, nil
+}
+
+// GetLatestID returns the highest stored transfer ID, or -1 when nothing has been stored yet.
+func (ts *TransfersService) GetLatestID() (int64, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})
+	result := ts.db.FindOne(context.Background(), bson.D{}, opts)
+	if result.Err() != nil {
+		if result.Err() == mongo.ErrNoDocuments {
+			return -1, nil
+		}
+		logger.Error("Error finding latest transfer", "error", result.Err())
+		return -1, result.Err()
+	}
+
+	var transfer models.TransferDetails
+	err := result.Decode(&transfer)
+	if err != nil {
+		logger.Error("Error decoding transfer", "error", err)
+		return -1, err
+	}
+	return transfer.ID, nil
+}
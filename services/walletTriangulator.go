@@ -17,6 +17,9 @@ import (
 const (
 	CREATE_POOL   = "CREATE_POOL"
 	ADD_LIQUIDITY = "ADD_LIQUIDITY"
+	RAYDIUM       = "RAYDIUM"
+
+	deployerHistoryMaxPages = 50
 )
 
 type WalletOccurence struct {
@@ -33,7 +36,7 @@ type FirstBuyerResult struct {
 type WalletTriangulatorService struct {
 	rpc          *rpc.Client
 	client       *http.Client
-	heliusClient *clients.HeliusClient
+	heliusClient clients.HeliusAPI
 }
 
 type TransactionRecord struct {
@@ -97,8 +100,8 @@ type AccountTransaction struct {
 	Signatures  []string                   `json:"signatures"`
 }
 
-func NewWalletTriangulatorService(rpcUrl string, hc *clients.HeliusClient) *WalletTriangulatorService {
-	return &WalletTriangulatorService{rpc: rpc.New(rpcUrl), client: &http.Client{Timeout: 10 * time.Second}, heliusClient: hc}
+func NewWalletTriangulatorService(rpcClient *rpc.Client, hc clients.HeliusAPI) *WalletTriangulatorService {
+	return &WalletTriangulatorService{rpc: rpcClient, client: &http.Client{Timeout: 10 * time.Second}, heliusClient: hc}
 }
 
 func (wts *WalletTriangulatorService) FindCommonAddressesInTokens(limit int, tokenAddresses []string) ([]WalletOccurence, error) {
@@ -164,17 +167,23 @@ func (wts *WalletTriangulatorService) GetFirstBuyersOfToken(tokenAddress string,
 	deployerAddress := tokenMintTransaction.SourceOwnerAccount
 	deploymentSignature := tokenMintTransaction.TxHash
 	logger.Info("Getting first buyers of token", "tokenAddress", tokenAddress, "deployerAddress", deployerAddress, "deploymentSignature", deploymentSignature)
-	deployerTransactions, err := wts.heliusClient.GetAccountTokenTransactions(deployerAddress, deploymentSignature)
-	if err != nil {
-		logger.Error("Error getting deployer transactions", "error", err, "tokenAddress", tokenAddress, "deployerAddress", deployerAddress, "deploymentSignature", deploymentSignature)
-		return []string{}, err
-	}
+	// The history runs newest first, so the last pool creation or liquidity addition seen is the first one made
+	deployerTransactions := wts.heliusClient.AddressTransactions(deployerAddress, clients.AddressTransactionsQuery{
+		Until:    deploymentSignature,
+		Source:   RAYDIUM,
+		MaxPages: deployerHistoryMaxPages,
+	})
 	var deploymentBlock uint64
-	for _, transaction := range deployerTransactions {
+	for deployerTransactions.Next() {
+		transaction := deployerTransactions.Transaction()
 		if transaction.TransactionType == CREATE_POOL || transaction.TransactionType == ADD_LIQUIDITY {
 			deploymentBlock = transaction.Slot
 		}
 	}
+	if err = deployerTransactions.Err(); err != nil {
+		logger.Error("Error getting deployer transactions", "error", err, "tokenAddress", tokenAddress, "deployerAddress", deployerAddress, "deploymentSignature", deploymentSignature)
+		return []string{}, err
+	}
 
 	effortlessCalls := 0
 	for len(addresses.m) < limit {
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"solana/models"
+	"sync"
+)
+
+// WalletNameCache keeps the names of the monitored wallets in memory so parsing a transaction does not need a
+// database round trip per account. It implements models.WalletNameResolver.
+type WalletNameCache struct {
+	sync.RWMutex
+	names map[string]string
+}
+
+func NewWalletNameCache() *WalletNameCache {
+	return &WalletNameCache{names: make(map[string]string)}
+}
+
+func (wnc *WalletNameCache) WalletName(publicKey string) string {
+	wnc.RLock()
+	defer wnc.RUnlock()
+	return wnc.names[publicKey]
+}
+
+// Load replaces the cached names with the names of the given wallets
+func (wnc *WalletNameCache) Load(wallets []*models.MonitoredWallet) {
+	names := make(map[string]string, len(wallets))
+	for _, wallet := range wallets {
+		names[wallet.PublicKey] = wallet.Name
+	}
+	wnc.Lock()
+	wnc.names = names
+	wnc.Unlock()
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"solana/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// walletsCollection answers Find with the stored wallets, or with err when it is set
+type walletsCollection struct {
+	DBService
+	wallets []interface{}
+	err     error
+}
+
+func (wc *walletsCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	if wc.err != nil {
+		return nil, wc.err
+	}
+	return mongo.NewCursorFromDocuments(wc.wallets, nil, nil)
+}
+
+func TestWalletNameCache(t *testing.T) {
+	t.Run("replaces the names on load", func(t *testing.T) {
+		names := NewWalletNameCache()
+		names.Load([]*models.MonitoredWallet{{Name: "Alice", PublicKey: "alice"}, {Name: "Bob", PublicKey: "bob"}})
+		names.Load([]*models.MonitoredWallet{{Name: "Bobby", PublicKey: "bob"}})
+		if name := names.WalletName("alice"); name != "" {
+			t.Errorf("Incorrect name %q for a removed wallet should be empty", name)
+		}
+		if name := names.WalletName("bob"); name != "Bobby" {
+			t.Errorf("Incorrect name %q should be %q", name, "Bobby")
+		}
+	})
+
+	t.Run("refreshes from the database", func(t *testing.T) {
+		names := NewWalletNameCache()
+		db := &walletsCollection{wallets: []interface{}{models.MonitoredWallet{Name: "Alice", PublicKey: "alice"}}}
+		service := NewMonitoredWalletsService(db, nil, names)
+		if err := service.RefreshWalletNames(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if name := names.WalletName("alice"); name != "Alice" {
+			t.Errorf("Incorrect name %q should be %q", name, "Alice")
+		}
+	})
+
+	t.Run("keeps the names when the refresh fails", func(t *testing.T) {
+		names := NewWalletNameCache()
+		names.Load([]*models.MonitoredWallet{{Name: "Alice", PublicKey: "alice"}})
+		service := NewMonitoredWalletsService(&walletsCollection{err: errors.New("connection refused")}, nil, names)
+		if err := service.RefreshWalletNames(); err == nil {
+			t.Error("Expected an error")
+		}
+		if name := names.WalletName("alice"); name != "Alice" {
+			t.Errorf("Incorrect name %q should be %q", name, "Alice")
+		}
+	})
+}
@@ -21,7 +21,7 @@ func NewWalletsService(db DBService, salt []byte) *WalletsService {
 func (ws *WalletsService) GetWalletByName(name string) (*models.Wallet, error) {
 	var wallet models.Wallet
 
-	result := ws.db.FindOne(context.Background(), bson.D{{"name", name}})
+	result := ws.db.FindOne(context.Background(), bson.D{{Key: "name", Value: name}})
 
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
@@ -92,7 +92,7 @@ func (ws *WalletsService) AddWallet(wallet *models.Wallet) error {
 }
 
 func (ws *WalletsService) DeleteWallet(name string) error {
-	_, err := ws.db.DeleteOne(context.Background(), bson.D{{"name", name}})
+	_, err := ws.db.DeleteOne(context.Background(), bson.D{{Key: "name", Value: name}})
 	if err != nil {
 		logger.Error("Error deleting wallet", "error", err)
 		return err
@@ -103,7 +103,7 @@ func (ws *WalletsService) DeleteWallet(name string) error {
 func (ws *WalletsService) UpdateWallet(name string, updatedWallet *models.Wallet) (*models.Wallet, error) {
 	var wallet models.Wallet
 
-	result := ws.db.FindOne(context.Background(), bson.D{{"name", name}})
+	result := ws.db.FindOne(context.Background(), bson.D{{Key: "name", Value: name}})
 
 	if result.Err() != nil {
 		if result.Err() == mongo.ErrNoDocuments {
@@ -127,7 +127,7 @@ func (ws *WalletsService) UpdateWallet(name string, updatedWallet *models.Wallet
 		return nil, err
 	}
 	wallet.PrivateKey = hashedPrivateKey
-	result = ws.db.FindOneAndReplace(context.Background(), bson.D{{"name", name}}, wallet)
+	result = ws.db.FindOneAndReplace(context.Background(), bson.D{{Key: "name", Value: name}}, wallet)
 	if result.Err() != nil {
 		logger.Error("Error updating wallet", "error", result.Err())
 		return nil, result.Err()
@@ -0,0 +1,73 @@
+package utils
+
+import "sync"
+
+// BoundedSet is a concurrency safe set holding at most capacity items. When full, the oldest item is evicted.
+type BoundedSet[T comparable] struct {
+	mu       sync.Mutex
+	items    map[T]uint64
+	order    []boundedSetEntry[T]
+	next     int
+	capacity int
+	added    uint64
+}
+
+type boundedSetEntry[T comparable] struct {
+	item T
+	seq  uint64
+}
+
+func NewBoundedSet[T comparable](capacity int) *BoundedSet[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &BoundedSet[T]{
+		items:    make(map[T]uint64, capacity),
+		order:    make([]boundedSetEntry[T], 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// Add inserts the item and reports whether it was not already present
+func (s *BoundedSet[T]) Add(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[item]; ok {
+		return false
+	}
+	s.added++
+	entry := boundedSetEntry[T]{item: item, seq: s.added}
+	if len(s.order) < s.capacity {
+		s.order = append(s.order, entry)
+	} else {
+		evicted := s.order[s.next]
+		// The evicted slot may belong to an item that was removed and added again since
+		if seq, ok := s.items[evicted.item]; ok && seq == evicted.seq {
+			delete(s.items, evicted.item)
+		}
+		s.order[s.next] = entry
+		s.next = (s.next + 1) % s.capacity
+	}
+	s.items[item] = entry.seq
+	return true
+}
+
+func (s *BoundedSet[T]) Contains(item T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[item]
+	return ok
+}
+
+// Remove deletes the item from the set. Its slot is only reclaimed once it would have been evicted.
+func (s *BoundedSet[T]) Remove(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, item)
+}
+
+func (s *BoundedSet[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
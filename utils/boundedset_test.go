@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestBoundedSet(t *testing.T) {
+	t.Run("Reports whether an item was newly added", func(t *testing.T) {
+		set := NewBoundedSet[string](3)
+		if !set.Add("a") {
+			t.Errorf("Expected first add to report a new item")
+		}
+		if set.Add("a") {
+			t.Errorf("Expected second add to report an existing item")
+		}
+		if !set.Contains("a") {
+			t.Errorf("Expected set to contain the added item")
+		}
+	})
+
+	t.Run("Evicts the oldest item when full", func(t *testing.T) {
+		set := NewBoundedSet[int](2)
+		set.Add(1)
+		set.Add(2)
+		set.Add(3)
+		if set.Contains(1) {
+			t.Errorf("Expected the oldest item to be evicted")
+		}
+		if !set.Contains(2) || !set.Contains(3) {
+			t.Errorf("Expected the newest items to be kept")
+		}
+		if set.Len() != 2 {
+			t.Errorf("Incorrect length %d should be %d", set.Len(), 2)
+		}
+	})
+
+	t.Run("Keeps an item re-added after removal until its new slot is evicted", func(t *testing.T) {
+		set := NewBoundedSet[int](2)
+		set.Add(1)
+		set.Remove(1)
+		set.Add(2)
+		set.Add(1)
+		set.Add(3)
+		if !set.Contains(1) {
+			t.Errorf("Expected the re-added item to survive eviction of its old slot")
+		}
+		if set.Contains(2) {
+			t.Errorf("Expected the oldest live item to be evicted")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package utils
+
+import "testing"
+
+func TestCompression(t *testing.T) {
+	t.Run("Decompresses what was compressed", func(t *testing.T) {
+		data := []byte(`[{"signature":"abc","type":"SWAP"}]`)
+		compressed, err := Compress(data)
+		if err != nil {
+			t.Fatalf("Error compressing data %s", err)
+		}
+		restored, err := Decompress(compressed)
+		if err != nil {
+			t.Fatalf("Error decompressing data %s", err)
+		}
+		if string(restored) != string(data) {
+			t.Errorf("Data was not restored, got %s", restored)
+		}
+	})
+
+	t.Run("Fails on data that is not compressed", func(t *testing.T) {
+		_, err := Decompress([]byte("plain"))
+		if err == nil {
+			t.Errorf("Expected an error for uncompressed data")
+		}
+	})
+}
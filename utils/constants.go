@@ -1,3 +1,5 @@
 package utils
 
 const SOL_ADDRESS = "So11111111111111111111111111111111111111112"
+
+const SOL_DECIMALS = 9
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// FormatUnits converts a raw integer amount into a decimal string with the given number of decimals, without
+// trailing zeros. "1500000000" with 9 decimals becomes "1.5".
+func FormatUnits(raw string, decimals int) (string, error) {
+	amount, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid raw amount %q", raw)
+	}
+	if decimals <= 0 {
+		return amount.String(), nil
+	}
+
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+		amount.Neg(amount)
+	}
+	digits := amount.String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return sign + whole, nil
+	}
+	return sign + whole + "." + fraction, nil
+}
+
+// MultiplyDecimal multiplies two decimal strings exactly and rounds the product to the given number of decimals
+func MultiplyDecimal(a, b string, decimals int) (string, error) {
+	x, ok := new(big.Rat).SetString(a)
+	if !ok {
+		return "", fmt.Errorf("invalid decimal %q", a)
+	}
+	y, ok := new(big.Rat).SetString(b)
+	if !ok {
+		return "", fmt.Errorf("invalid decimal %q", b)
+	}
+	return x.Mul(x, y).FloatString(decimals), nil
+}
+
+// FormatRat rounds the number to the given number of decimals and drops trailing zeros
+func FormatRat(number *big.Rat, decimals int) string {
+	formatted := number.FloatString(decimals)
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+	if formatted == "-0" {
+		return "0"
+	}
+	return formatted
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatUnits(t *testing.T) {
+	cases := []struct {
+		raw      string
+		decimals int
+		expected string
+	}{
+		{"1500000000", 9, "1.5"},
+		{"1000000000", 9, "1"},
+		{"5000", 9, "0.000005"},
+		{"-250000", 6, "-0.25"},
+		{"0", 6, "0"},
+		{"42", 0, "42"},
+		{"123456789012345678901234567890", 18, "123456789012.34567890123456789"},
+	}
+	for _, c := range cases {
+		amount, err := FormatUnits(c.raw, c.decimals)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", c.raw, err)
+			continue
+		}
+		if amount != c.expected {
+			t.Errorf("Incorrect amount for %s with %d decimals: got %s want %s", c.raw, c.decimals, amount, c.expected)
+		}
+	}
+
+	t.Run("Rejects amounts that are not integers", func(t *testing.T) {
+		if _, err := FormatUnits("1.5", 9); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestMultiplyDecimal(t *testing.T) {
+	t.Run("Rounds the product", func(t *testing.T) {
+		value, err := MultiplyDecimal("1.5", "142.3371", 2)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if value != "213.51" {
+			t.Errorf("Incorrect value %s should be %s", value, "213.51")
+		}
+	})
+	t.Run("Rejects invalid decimals", func(t *testing.T) {
+		if _, err := MultiplyDecimal("abc", "1", 2); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestFormatRat(t *testing.T) {
+	cases := map[string]string{
+		"3/2":             "1.5",
+		"-1/3":            "-0.333333333",
+		"2":               "2",
+		"-1/100000000000": "0",
+	}
+	for number, expected := range cases {
+		rat, _ := new(big.Rat).SetString(number)
+		if formatted := FormatRat(rat, 9); formatted != expected {
+			t.Errorf("Incorrect format of %s: got %s want %s", number, formatted, expected)
+		}
+	}
+}
@@ -1,30 +1,62 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt"
 	"os"
 	"time"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
+// ErrMissingJWTSecret is returned when JWT_SECRET is not set. Tokens signed with an empty key could be forged by
+// anyone, so none are issued or accepted.
+var ErrMissingJWTSecret = errors.New("JWT_SECRET is not set")
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 type Claims struct {
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
 	jwt.StandardClaims
 }
 
-// GenerateToken generates a new JWT token
-func GenerateToken(username string) (string, error) {
+// jwtKey reads the signing key when it is used rather than at startup of the package, which runs before the .env
+// file is loaded
+func jwtKey() ([]byte, error) {
+	key := os.Getenv("JWT_SECRET")
+	if key == "" {
+		return nil, ErrMissingJWTSecret
+	}
+	return []byte(key), nil
+}
+
+// CheckJWTSecret returns ErrMissingJWTSecret when tokens cannot be signed or validated
+func CheckJWTSecret() error {
+	_, err := jwtKey()
+	return err
+}
+
+// GenerateToken generates a new JWT token carrying the role of the user
+func GenerateToken(username string, role string) (string, error) {
+	key, err := jwtKey()
+	if err != nil {
+		return "", err
+	}
+
 	expirationTime := time.Now().Add(72 * time.Hour)
 	claims := &Claims{
 		Username: username,
+		Role:     role,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return token.SignedString(key)
 }
 
 // ValidateToken validates the JWT token
@@ -32,7 +64,10 @@ func ValidateToken(tokenString string) (*Claims, bool) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return jwtKey()
 	})
 
 	if err != nil {
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt"
+	"testing"
+)
+
+func TestJWT(t *testing.T) {
+	t.Run("reads the secret when signing and validating", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "test-secret")
+		token, err := GenerateToken("alice", RoleAdmin)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		claims, valid := ValidateToken(token)
+		if !valid || claims.Username != "alice" || claims.Role != RoleAdmin {
+			t.Errorf("Incorrect claims %+v", claims)
+		}
+	})
+
+	t.Run("refuses to work without a secret", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "")
+		if _, err := GenerateToken("alice", RoleUser); !errors.Is(err, ErrMissingJWTSecret) {
+			t.Errorf("Expected ErrMissingJWTSecret, got %v", err)
+		}
+		// A token anyone could sign with the empty key
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Username: "mallory", Role: RoleAdmin}).SignedString([]byte(""))
+		if _, valid := ValidateToken(forged); valid {
+			t.Error("Expected a token signed with an empty key to be rejected")
+		}
+	})
+}
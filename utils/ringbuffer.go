@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RingBuffer is a concurrency safe buffer of values ordered by an increasing int64 ID. It holds at most capacity
+// values and, with a positive TTL, only those added within the TTL. When full, the value with the lowest ID is evicted.
+type RingBuffer[T any] struct {
+	mu       sync.Mutex
+	entries  []ringBufferEntry[T]
+	head     int
+	size     int
+	ttl      time.Duration
+	now      func() time.Time
+	added    uint64
+	bySize   uint64
+	byAge    uint64
+	rejected uint64
+}
+
+type ringBufferEntry[T any] struct {
+	id      int64
+	value   T
+	addedAt time.Time
+}
+
+// RingBufferStats counts the values a ring buffer holds and the ones it let go
+type RingBufferStats struct {
+	Len           int    `json:"len"`
+	Capacity      int    `json:"capacity"`
+	TTLSeconds    int64  `json:"ttlSeconds"`
+	Added         uint64 `json:"added"`
+	EvictedBySize uint64 `json:"evictedBySize"`
+	EvictedByAge  uint64 `json:"evictedByAge"`
+	Rejected      uint64 `json:"rejected"`
+}
+
+// NewRingBuffer returns a buffer of the given capacity. A TTL of zero or less keeps values until they are evicted by size.
+func NewRingBuffer[T any](capacity int, ttl time.Duration) *RingBuffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &RingBuffer[T]{entries: make([]ringBufferEntry[T], capacity), ttl: ttl, now: time.Now}
+}
+
+// at returns the i-th entry in ID order
+func (b *RingBuffer[T]) at(i int) *ringBufferEntry[T] {
+	return &b.entries[(b.head+i)%len(b.entries)]
+}
+
+// search returns the position of the first entry with an ID greater than the given one
+func (b *RingBuffer[T]) search(id int64) int {
+	return sort.Search(b.size, func(i int) bool {
+		return b.at(i).id > id
+	})
+}
+
+func (b *RingBuffer[T]) evictOldest() {
+	*b.at(0) = ringBufferEntry[T]{}
+	b.head = (b.head + 1) % len(b.entries)
+	b.size--
+}
+
+// expire evicts the entries older than the TTL. IDs are assigned in arrival order, so they are found at the front.
+func (b *RingBuffer[T]) expire() {
+	if b.ttl <= 0 {
+		return
+	}
+	cutoff := b.now().Add(-b.ttl)
+	for b.size > 0 && b.at(0).addedAt.Before(cutoff) {
+		b.evictOldest()
+		b.byAge++
+	}
+}
+
+// Put adds the value under the ID, replacing the value already held under it. Values usually arrive in ID order,
+// one that arrives late is moved into place. It is rejected when the buffer is full of values with higher IDs.
+func (b *RingBuffer[T]) Put(id int64, value T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+
+	position := b.search(id)
+	if position > 0 && b.at(position-1).id == id {
+		b.at(position - 1).value = value
+		return
+	}
+	if b.size == len(b.entries) {
+		if position == 0 {
+			b.rejected++
+			return
+		}
+		b.evictOldest()
+		b.bySize++
+		position--
+	}
+
+	b.size++
+	for i := b.size - 1; i > position; i-- {
+		*b.at(i) = *b.at(i - 1)
+	}
+	*b.at(position) = ringBufferEntry[T]{id: id, value: value, addedAt: b.now()}
+	b.added++
+}
+
+// Replace swaps the value held under the ID and reports whether there was one
+func (b *RingBuffer[T]) Replace(id int64, value T) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+
+	position := b.search(id)
+	if position == 0 || b.at(position-1).id != id {
+		return false
+	}
+	b.at(position - 1).value = value
+	return true
+}
+
+// After returns the values with an ID greater than the given one in ID order, and the lowest ID the buffer holds.
+// Without values the lowest ID is reported as not ok.
+func (b *RingBuffer[T]) After(id int64) (values []T, oldest int64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+
+	values = make([]T, 0, b.size)
+	for i := b.search(id); i < b.size; i++ {
+		values = append(values, b.at(i).value)
+	}
+	if b.size == 0 {
+		return values, 0, false
+	}
+	return values, b.at(0).id, true
+}
+
+// All returns every value in ID order
+func (b *RingBuffer[T]) All() []T {
+	values, _, _ := b.After(math.MinInt64)
+	return values
+}
+
+// Clear drops every value without counting them as evicted
+func (b *RingBuffer[T]) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = make([]ringBufferEntry[T], len(b.entries))
+	b.head = 0
+	b.size = 0
+}
+
+func (b *RingBuffer[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	return b.size
+}
+
+func (b *RingBuffer[T]) Stats() RingBufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	return RingBufferStats{
+		Len:           b.size,
+		Capacity:      len(b.entries),
+		TTLSeconds:    int64(b.ttl / time.Second),
+		Added:         b.added,
+		EvictedBySize: b.bySize,
+		EvictedByAge:  b.byAge,
+		Rejected:      b.rejected,
+	}
+}
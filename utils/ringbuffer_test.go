@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRingBuffer(t *testing.T) {
+	t.Run("Returns values after an ID in ID order", func(t *testing.T) {
+		buffer := NewRingBuffer[string](5, 0)
+		buffer.Put(1, "a")
+		buffer.Put(3, "c")
+		buffer.Put(2, "b")
+		buffer.Put(4, "d")
+
+		values, oldest, ok := buffer.After(1)
+		if len(values) != 3 || values[0] != "b" || values[1] != "c" || values[2] != "d" {
+			t.Errorf("Incorrect values %v should be %v", values, []string{"b", "c", "d"})
+		}
+		if !ok || oldest != 1 {
+			t.Errorf("Incorrect oldest ID %d should be %d", oldest, 1)
+		}
+		if values, _, _ := buffer.After(4); len(values) != 0 {
+			t.Errorf("Expected no values after the newest ID, got %v", values)
+		}
+	})
+
+	t.Run("Evicts the lowest IDs when full", func(t *testing.T) {
+		buffer := NewRingBuffer[int64](3, 0)
+		for id := int64(0); id < 7; id++ {
+			buffer.Put(id, id)
+		}
+		values := buffer.All()
+		if len(values) != 3 || values[0] != 4 || values[2] != 6 {
+			t.Errorf("Incorrect values %v should be %v", values, []int64{4, 5, 6})
+		}
+		buffer.Put(2, 2)
+		stats := buffer.Stats()
+		if stats.Len != 3 || stats.Added != 7 || stats.EvictedBySize != 4 || stats.Rejected != 1 {
+			t.Errorf("Incorrect stats %+v", stats)
+		}
+	})
+
+	t.Run("Expires values older than the TTL", func(t *testing.T) {
+		now := time.Unix(1700000000, 0)
+		buffer := NewRingBuffer[int64](10, time.Minute)
+		buffer.now = func() time.Time { return now }
+		buffer.Put(1, 1)
+		now = now.Add(30 * time.Second)
+		buffer.Put(2, 2)
+		now = now.Add(45 * time.Second)
+
+		values, oldest, _ := buffer.After(0)
+		if len(values) != 1 || oldest != 2 {
+			t.Errorf("Incorrect values %v with oldest ID %d, expected only ID 2", values, oldest)
+		}
+		if evicted := buffer.Stats().EvictedByAge; evicted != 1 {
+			t.Errorf("Incorrect age evictions %d should be %d", evicted, 1)
+		}
+	})
+
+	t.Run("Replaces only values it holds", func(t *testing.T) {
+		buffer := NewRingBuffer[string](2, 0)
+		buffer.Put(1, "a")
+		if !buffer.Replace(1, "b") {
+			t.Errorf("Expected the held value to be replaced")
+		}
+		if buffer.Replace(2, "c") {
+			t.Errorf("Expected a missing value not to be replaced")
+		}
+		if values := buffer.All(); len(values) != 1 || values[0] != "b" {
+			t.Errorf("Incorrect values %v should be %v", values, []string{"b"})
+		}
+	})
+}